@@ -0,0 +1,230 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// assignMatches resolves GitHub logins that were found for more than one
+// employee, which happens with common or similar names. Every contested
+// login goes to one employee, chosen by a Hungarian assignment that gives
+// each employee at most one contested login and maximises the total score.
+// Logins found for a single employee are kept as they are. The matches that
+// lost are returned separately, and a winner is marked Ambiguous when another
+// employee scored at least as high for the same login. Duplicates of a match
+// are merged first so they do not compete with each other.
+func assignMatches(matches []Match) (kept []Match, discarded []Match) {
+	matches = mergeDuplicateMatches(matches)
+	byLogin := make(map[string][]int)
+	for i, match := range matches {
+		byLogin[match.Login] = append(byLogin[match.Login], i)
+	}
+
+	var logins []string
+	for login, indexes := range byLogin {
+		if len(indexes) > 1 {
+			logins = append(logins, login)
+		}
+	}
+	if len(logins) == 0 {
+		return matches, nil
+	}
+	sort.Strings(logins)
+
+	employeeIndex := make(map[string]int)
+	var employees []string
+	for _, login := range logins {
+		for _, i := range byLogin[login] {
+			key := employeeKey(matches[i].Employee)
+			if _, ok := employeeIndex[key]; !ok {
+				employeeIndex[key] = len(employees)
+				employees = append(employees, key)
+			}
+		}
+	}
+
+	// scores[e][l] is the score of contested login l for employee e, or -1
+	// when the login was not found for that employee.
+	scores := make([][]float64, len(employees))
+	for e := range scores {
+		scores[e] = make([]float64, len(logins))
+		for l := range scores[e] {
+			scores[e][l] = -1
+		}
+	}
+	for l, login := range logins {
+		for _, i := range byLogin[login] {
+			e := employeeIndex[employeeKey(matches[i].Employee)]
+			if matches[i].Score > scores[e][l] {
+				scores[e][l] = matches[i].Score
+			}
+		}
+	}
+
+	winner := make(map[string]string)
+	for e, l := range hungarian(scores) {
+		if l >= 0 && scores[e][l] >= 0 {
+			winner[logins[l]] = employees[e]
+		}
+	}
+
+	// With more contested logins than employees some logins are left over,
+	// they go to whoever scored highest for them.
+	for l, login := range logins {
+		if _, ok := winner[login]; ok {
+			continue
+		}
+		best := -1
+		for e := range employees {
+			if scores[e][l] >= 0 && (best < 0 || scores[e][l] > scores[best][l]) {
+				best = e
+			}
+		}
+		winner[login] = employees[best]
+	}
+
+	for l, login := range logins {
+		for _, i := range byLogin[login] {
+			if employeeKey(matches[i].Employee) != winner[login] {
+				continue
+			}
+			for _, j := range byLogin[login] {
+				other := matches[j]
+				if employeeKey(other.Employee) == winner[login] {
+					continue
+				}
+				matches[i].Contenders = append(matches[i].Contenders, other.Employee.Name)
+				if scores[employeeIndex[employeeKey(other.Employee)]][l] >= matches[i].Score {
+					matches[i].Ambiguous = true
+				}
+			}
+		}
+	}
+
+	for _, match := range matches {
+		if len(byLogin[match.Login]) > 1 && winner[match.Login] != employeeKey(match.Employee) {
+			discarded = append(discarded, match)
+			continue
+		}
+		kept = append(kept, match)
+	}
+	return kept, discarded
+}
+
+// mergeDuplicateMatches collapses the matches of one login for one employee,
+// which happen when the employee is listed twice. The copy with the highest
+// score is kept, with the evidence of the others added to it.
+func mergeDuplicateMatches(matches []Match) []Match {
+	index := make(map[string]int)
+	var merged []Match
+	for _, match := range matches {
+		key := matchKey(match)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, match)
+			continue
+		}
+
+		best, other := merged[i], match
+		if other.Score > best.Score {
+			best, other = other, best
+		}
+		seen := make(map[string]bool)
+		best.Evidence = append([]Evidence(nil), best.Evidence...)
+		for _, evidence := range best.Evidence {
+			seen[evidenceKey(evidence)] = true
+		}
+		for _, evidence := range other.Evidence {
+			if !seen[evidenceKey(evidence)] {
+				seen[evidenceKey(evidence)] = true
+				best.Evidence = append(best.Evidence, evidence)
+			}
+		}
+		merged[i] = best
+	}
+	return merged
+}
+
+// hungarian solves the assignment problem for a rows by columns matrix of
+// scores, returning for every row the column assigned to it or -1. Negative
+// scores mark pairs that must not be assigned and are treated as worth
+// nothing.
+func hungarian(scores [][]float64) []int {
+	rows := len(scores)
+	if rows == 0 {
+		return nil
+	}
+	columns := len(scores[0])
+	n := rows
+	if columns > n {
+		n = columns
+	}
+
+	// The classic formulation minimises cost over a square matrix, so pad it
+	// and negate the scores.
+	cost := func(row, column int) float64 {
+		if row >= rows || column >= columns || scores[row][column] < 0 {
+			return 0
+		}
+		return -scores[row][column]
+	}
+
+	u := make([]float64, n+1)
+	v := make([]float64, n+1)
+	p := make([]int, n+1)
+	way := make([]int, n+1)
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, n+1)
+		used := make([]bool, n+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		for {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.Inf(1), 0
+			for j := 1; j <= n; j++ {
+				if used[j] {
+					continue
+				}
+				current := cost(i0-1, j-1) - u[i0] - v[j]
+				if current < minv[j] {
+					minv[j], way[j] = current, j0
+				}
+				if minv[j] < delta {
+					delta, j1 = minv[j], j
+				}
+			}
+			for j := 0; j <= n; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+
+	assignment := make([]int, rows)
+	for i := range assignment {
+		assignment[i] = -1
+	}
+	for j := 1; j <= n; j++ {
+		if p[j] > 0 && p[j] <= rows && j <= columns {
+			assignment[p[j]-1] = j - 1
+		}
+	}
+	return assignment
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHungarian(t *testing.T) {
+	tests := []struct {
+		name   string
+		scores [][]float64
+		want   []int
+	}{
+		{name: "empty", scores: nil, want: nil},
+		{name: "diagonal", scores: [][]float64{{3, 1}, {2, 2}}, want: []int{0, 1}},
+		{name: "greedy would lose", scores: [][]float64{{5, 4}, {4, 1}}, want: []int{1, 0}},
+		{name: "more rows than columns", scores: [][]float64{{1}, {3}}, want: []int{-1, 0}},
+		{name: "more columns than rows", scores: [][]float64{{1, 5, 2}}, want: []int{1}},
+		{name: "forbidden pairs", scores: [][]float64{{-1, 2}, {3, -1}}, want: []int{1, 0}},
+		{
+			name:   "three by three",
+			scores: [][]float64{{4, 1, 3}, {2, 0, 5}, {3, 2, 2}},
+			want:   []int{0, 2, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hungarian(tt.scores); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hungarian() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssignMatches(t *testing.T) {
+	alice := Employee{Name: "Alice", Location: "Berlin"}
+	bob := Employee{Name: "Bob", Location: "Berlin"}
+	match := func(employee Employee, login string, score float64) Match {
+		return Match{Employee: employee, Login: login, Score: score}
+	}
+	type result struct {
+		Employee   string
+		Login      string
+		Contenders []string
+		Ambiguous  bool
+	}
+	summarise := func(matches []Match) []result {
+		var results []result
+		for _, m := range matches {
+			results = append(results, result{m.Employee.Name, m.Login, m.Contenders, m.Ambiguous})
+		}
+		return results
+	}
+
+	tests := []struct {
+		name          string
+		matches       []Match
+		wantKept      []result
+		wantDiscarded []result
+	}{
+		{
+			name:     "nothing contested",
+			matches:  []Match{match(alice, "alice", 3), match(bob, "bob", 1)},
+			wantKept: []result{{"Alice", "alice", nil, false}, {"Bob", "bob", nil, false}},
+		},
+		{
+			name:          "higher score wins",
+			matches:       []Match{match(alice, "ab", 5), match(bob, "ab", 3), match(bob, "bob", 1)},
+			wantKept:      []result{{"Alice", "ab", []string{"Bob"}, false}, {"Bob", "bob", nil, false}},
+			wantDiscarded: []result{{"Bob", "ab", nil, false}},
+		},
+		{
+			name:          "tie is ambiguous",
+			matches:       []Match{match(alice, "ab", 3), match(bob, "ab", 3)},
+			wantKept:      []result{{"Alice", "ab", []string{"Bob"}, true}},
+			wantDiscarded: []result{{"Bob", "ab", nil, false}},
+		},
+		{
+			name: "one contested login per employee",
+			matches: []Match{
+				match(alice, "a", 5), match(alice, "b", 4),
+				match(bob, "a", 4), match(bob, "b", 1),
+			},
+			wantKept:      []result{{"Alice", "b", []string{"Bob"}, false}, {"Bob", "a", []string{"Alice"}, true}},
+			wantDiscarded: []result{{"Alice", "a", nil, false}, {"Bob", "b", nil, false}},
+		},
+		{
+			name:     "employee listed twice",
+			matches:  []Match{match(alice, "alice", 3), match(bob, "bob", 1), match(alice, "alice", 5)},
+			wantKept: []result{{"Alice", "alice", nil, false}, {"Bob", "bob", nil, false}},
+		},
+		{
+			name:          "employee listed twice contests once",
+			matches:       []Match{match(alice, "ab", 5), match(bob, "ab", 3), match(alice, "ab", 5)},
+			wantKept:      []result{{"Alice", "ab", []string{"Bob"}, false}},
+			wantDiscarded: []result{{"Bob", "ab", nil, false}},
+		},
+		{
+			name:          "left over login goes to the best score",
+			matches:       []Match{match(alice, "a", 5), match(alice, "b", 2), match(bob, "a", 1), match(bob, "b", 3)},
+			wantKept:      []result{{"Alice", "a", []string{"Bob"}, false}, {"Bob", "b", []string{"Alice"}, false}},
+			wantDiscarded: []result{{"Alice", "b", nil, false}, {"Bob", "a", nil, false}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, discarded := assignMatches(tt.matches)
+			if got := summarise(kept); !reflect.DeepEqual(got, tt.wantKept) {
+				t.Errorf("kept = %+v, want %+v", got, tt.wantKept)
+			}
+			if got := summarise(discarded); !reflect.DeepEqual(got, tt.wantDiscarded) {
+				t.Errorf("discarded = %+v, want %+v", got, tt.wantDiscarded)
+			}
+		})
+	}
+}
+
+func TestMergeDuplicateMatches(t *testing.T) {
+	alice := Employee{Name: "Alice", Location: "Berlin"}
+	location := Evidence{Kind: evidenceLocation, Detail: "Berlin matches Berlin"}
+	keyword := Evidence{Kind: evidenceKeywordCode, Keyword: "acme", Detail: "keyword in alice/site/README.md"}
+
+	tests := []struct {
+		name    string
+		matches []Match
+		want    []Match
+	}{
+		{
+			name:    "no duplicates",
+			matches: []Match{{Employee: alice, Login: "alice", Score: 2}, {Employee: alice, Login: "asmith", Score: 2}},
+			want:    []Match{{Employee: alice, Login: "alice", Score: 2}, {Employee: alice, Login: "asmith", Score: 2}},
+		},
+		{
+			name: "highest score kept and evidence merged",
+			matches: []Match{
+				{Employee: alice, Login: "alice", Score: 2, Evidence: []Evidence{location}},
+				{Employee: alice, Login: "alice", Score: 3, Evidence: []Evidence{keyword, location}},
+			},
+			want: []Match{{Employee: alice, Login: "alice", Score: 3, Evidence: []Evidence{keyword, location}}},
+		},
+		{
+			name: "evidence of the lower score added",
+			matches: []Match{
+				{Employee: alice, Login: "alice", Score: 3, Evidence: []Evidence{keyword}},
+				{Employee: alice, Login: "alice", Score: 2, Evidence: []Evidence{location}},
+			},
+			want: []Match{{Employee: alice, Login: "alice", Score: 3, Evidence: []Evidence{keyword, location}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeDuplicateMatches(tt.matches); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeDuplicateMatches() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
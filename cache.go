@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// cacheStatusHeader is added to responses passing through the cache and tells
+// whether they came from disk (hit), were confirmed by a 304 (revalidated) or
+// were fetched (miss).
+const cacheStatusHeader = "X-Mulef-Cache"
+
+const defaultCacheTTL = 24 * time.Hour
+
+// cachedStatuses are the GitHub responses worth keeping. 404 and 204 are the
+// answers of the organization membership check.
+var cachedStatuses = map[int]bool{
+	http.StatusOK:        true,
+	http.StatusNoContent: true,
+	http.StatusNotFound:  true,
+}
+
+// rateLimitHeaders are the headers in which GitHub reports a rate limit.
+var rateLimitHeaders = []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Used", "X-RateLimit-Reset", "X-RateLimit-Resource"}
+
+// cacheEntry is one response stored on disk.
+type cacheEntry struct {
+	URL          string      `json:"url"`
+	StatusCode   int         `json:"status_code"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	StoredAt     time.Time   `json:"stored_at"`
+}
+
+// cachingTransport keeps GitHub API responses in dir. Entries younger than
+// ttl are answered from disk, older ones are revalidated with If-None-Match or
+// If-Modified-Since, and GitHub does not count a 304 against the rate limit.
+type cachingTransport struct {
+	next http.RoundTripper
+	dir  string
+	ttl  time.Duration
+}
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "mulef")
+}
+
+// cacheable limits the cache to GitHub REST reads. The rate limit check must
+// always be live and LinkedIn responses depend on the session.
+func cacheable(req *http.Request) bool {
+	return req.Method == "GET" && req.URL.Host == "api.github.com" && req.URL.Path != "/rate_limit"
+}
+
+// cacheKey identifies a response by its URL and the token it was fetched
+// with, as what GitHub shows depends on the token's scopes.
+func cacheKey(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.URL.String() + "\n" + req.Header.Get("Accept") + "\n" + req.Header.Get("Authorization")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *cachingTransport) path(key string) string {
+	return filepath.Join(t.dir, key[:2], key+".json")
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !cacheable(req) {
+		return t.next.RoundTrip(req)
+	}
+	key := cacheKey(req)
+	entry, _ := t.load(key)
+	if entry != nil && time.Since(entry.StoredAt) < t.ttl {
+		return entry.response(req, "hit"), nil
+	}
+
+	if entry != nil && (entry.ETag != "" || entry.LastModified != "") {
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		entry.StoredAt = time.Now()
+		t.store(key, entry)
+		revalidated := entry.response(req, "revalidated")
+		// The stored headers tell the rate limit left when the entry was
+		// fetched, the 304 tells the one left now.
+		for _, name := range rateLimitHeaders {
+			revalidated.Header.Del(name)
+			for _, value := range resp.Header.Values(name) {
+				revalidated.Header.Add(name, value)
+			}
+		}
+		return revalidated, nil
+	}
+	if !cachedStatuses[resp.StatusCode] {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	t.store(key, &cacheEntry{
+		URL:          req.URL.String(),
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header,
+		Body:         body,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		StoredAt:     time.Now(),
+	})
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.Header.Set(cacheStatusHeader, "miss")
+	return resp, nil
+}
+
+func (t *cachingTransport) load(key string) (*cacheEntry, error) {
+	data, err := ioutil.ReadFile(t.path(key))
+	if err != nil {
+		return nil, err
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// store writes the entry to a temporary file first so a concurrent reader
+// never sees half of it. A cache that can not be written is not an error.
+func (t *cachingTransport) store(key string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	path := t.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "entry")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func (e *cacheEntry) response(req *http.Request, status string) *http.Response {
+	header := e.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(cacheStatusHeader, status)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// fromCache reports whether resp was answered without spending rate limit.
+func fromCache(resp *http.Response) bool {
+	status := resp.Header.Get(cacheStatusHeader)
+	return status == "hit" || status == "revalidated"
+}
+
+// githubPauseDuration is how long githubPause waits.
+const githubPauseDuration = 500 * time.Millisecond
+
+// githubPause spaces out GitHub requests to stay clear of the secondary rate
+// limits, and waits for the reset when resp used up a rate limit. Answers
+// from the cache did not reach GitHub and need no pause. It returns early
+// when ctx is cancelled.
+func githubPause(ctx context.Context, resp *http.Response) {
+	if resp != nil && fromCache(resp) {
+		return
+	}
+	pause := githubPauseDuration
+	if resp != nil {
+		if wait := rateLimitWait(resp.Header); wait > pause {
+			color.Yellow("[!] GitHub %s rate limit used up, waiting %s for it to reset", resp.Header.Get("X-RateLimit-Resource"), wait.Round(time.Second))
+			pause = wait
+		}
+	}
+	started := time.Now()
+	timer := time.NewTimer(pause)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	if pause > githubPauseDuration {
+		rateLimitWaitsMetric.add(1)
+		rateLimitWaitSecondsMetric.add(time.Since(started).Seconds())
+	} else {
+		throttlePausesMetric.add(1)
+	}
+}
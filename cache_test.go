@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCachingTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		ttl        time.Duration
+		status     int
+		etag       string
+		wantCache  []string
+		wantCalls  int
+		wantStatus int
+	}{
+		{
+			name:       "fresh entry is served from disk",
+			url:        "https://api.github.com/users/alice",
+			ttl:        time.Hour,
+			status:     http.StatusOK,
+			wantCache:  []string{"miss", "hit", "hit"},
+			wantCalls:  1,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "stale entry is revalidated with its ETag",
+			url:        "https://api.github.com/users/alice",
+			ttl:        -time.Second,
+			status:     http.StatusOK,
+			etag:       `"v1"`,
+			wantCache:  []string{"miss", "revalidated", "revalidated"},
+			wantCalls:  3,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "stale entry without validators is fetched again",
+			url:        "https://api.github.com/users/alice",
+			ttl:        -time.Second,
+			status:     http.StatusOK,
+			wantCache:  []string{"miss", "miss"},
+			wantCalls:  2,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "not found is cached",
+			url:        "https://api.github.com/orgs/acme/members/alice",
+			ttl:        time.Hour,
+			status:     http.StatusNotFound,
+			wantCache:  []string{"miss", "hit"},
+			wantCalls:  1,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "server errors are not cached",
+			url:        "https://api.github.com/users/alice",
+			ttl:        time.Hour,
+			status:     http.StatusBadGateway,
+			wantCache:  []string{"", ""},
+			wantCalls:  2,
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name:       "rate limit is always live",
+			url:        "https://api.github.com/rate_limit",
+			ttl:        time.Hour,
+			status:     http.StatusOK,
+			wantCache:  []string{"", ""},
+			wantCalls:  2,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "LinkedIn is not cached",
+			url:        "https://www.linkedin.com/voyager/api/graphql",
+			ttl:        time.Hour,
+			status:     http.StatusOK,
+			wantCache:  []string{"", ""},
+			wantCalls:  2,
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			transport := &cachingTransport{
+				dir: t.TempDir(),
+				ttl: tt.ttl,
+				next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					calls++
+					if tt.etag != "" && req.Header.Get("If-None-Match") == tt.etag {
+						return stubResponse(http.StatusNotModified, ""), nil
+					}
+					resp := stubResponse(tt.status, `{"login":"alice"}`)
+					if tt.etag != "" {
+						resp.Header.Set("ETag", tt.etag)
+					}
+					return resp, nil
+				}),
+			}
+
+			for i, want := range tt.wantCache {
+				req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+				req.Header.Set("Authorization", "Bearer token")
+				resp, err := transport.RoundTrip(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if got := resp.Header.Get(cacheStatusHeader); got != want {
+					t.Errorf("request %d: cache status = %q, want %q", i, got, want)
+				}
+				if resp.StatusCode != tt.wantStatus || string(body) != `{"login":"alice"}` {
+					t.Errorf("request %d: got HTTP %d %s", i, resp.StatusCode, body)
+				}
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("%d requests reached GitHub, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCachingTransportKeysOnToken(t *testing.T) {
+	calls := 0
+	transport := &cachingTransport{
+		dir: t.TempDir(),
+		ttl: time.Hour,
+		next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			return stubResponse(http.StatusOK, `{}`), nil
+		}),
+	}
+	for _, token := range []string{"a", "b", "a"} {
+		req, _ := http.NewRequest(http.MethodGet, "https://api.github.com/users/alice", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if calls != 2 {
+		t.Errorf("%d requests reached GitHub, want one per token", calls)
+	}
+}
+
+func TestGitHubPause(t *testing.T) {
+	cached := stubResponse(http.StatusOK, `{}`)
+	cached.Header.Set(cacheStatusHeader, "hit")
+	usedUp := stubResponse(http.StatusOK, `{}`)
+	usedUp.Header = rateLimitHeader("core", 5000, 0, time.Now().Add(time.Hour))
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		resp     *http.Response
+		min, max time.Duration
+	}{
+		{name: "throttle", ctx: context.Background(), resp: stubResponse(http.StatusOK, `{}`), min: githubPauseDuration, max: 2 * githubPauseDuration},
+		{name: "cache hit", ctx: context.Background(), resp: cached, max: 50 * time.Millisecond},
+		{name: "cancelled throttle", ctx: cancelled, resp: stubResponse(http.StatusOK, `{}`), max: 50 * time.Millisecond},
+		{name: "cancelled reset wait", ctx: cancelled, resp: usedUp, max: 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := time.Now()
+			githubPause(tt.ctx, tt.resp)
+			if elapsed := time.Since(started); elapsed < tt.min || elapsed > tt.max {
+				t.Errorf("githubPause took %s, want between %s and %s", elapsed, tt.min, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,667 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+const usageText = `Usage: mulef <command> [flags]
+
+Commands:
+  linkedin fetch   fetch the employees of a company from LinkedIn and save them
+  github match     look for the GitHub accounts of a saved employee list
+  run              fetch the employees and match them in one go
+  report           summarise the results of a matching run
+  review           confirm or reject the matches of a run one by one
+  store            import, list and export the runs kept in a SQLite store
+  diff             show what changed between two runs
+  watch            rerun a target on a schedule and send what changed to sinks
+  sink test        send a made up match to sinks to check a webhook
+  serve            run jobs submitted over an HTTP API
+  config validate  check a config file and its profiles
+  token check      check the GitHub token and show its rate limit
+  token save       store a GitHub token for mulef to use
+
+Run "mulef <command> -h" for the flags of a command. Without a command,
+mulef behaves like "mulef run".
+`
+
+// runCommand dispatches the command line to a subcommand.
+func runCommand(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "-help" && args[0] != "--help" {
+		return runRun("mulef", args)
+	}
+
+	switch args[0] {
+	case "run":
+		return runRun("mulef run", args[1:])
+	case "linkedin":
+		if len(args) > 1 && (args[1] == "fetch" || args[1] == "export") {
+			return runLinkedInFetch("mulef linkedin "+args[1], args[2:])
+		}
+		fmt.Fprint(os.Stderr, "Usage: mulef linkedin fetch [flags]\n")
+		return flag.ErrHelp
+	case "github":
+		if len(args) > 1 && args[1] == "match" {
+			return runGitHubMatch("mulef github match", args[2:])
+		}
+		fmt.Fprint(os.Stderr, "Usage: mulef github match [flags]\n")
+		return flag.ErrHelp
+	case "report":
+		return runReport("mulef report", args[1:])
+	case "review":
+		return runReview("mulef review", args[1:])
+	case "store":
+		return runStore(args[1:])
+	case "diff":
+		return runDiff("mulef diff", args[1:])
+	case "watch":
+		return runWatch("mulef watch", args[1:])
+	case "sink":
+		return runSink(args[1:])
+	case "serve":
+		return runServe("mulef serve", args[1:])
+	case "config":
+		return runConfig(args[1:])
+	case "token":
+		return runToken(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(os.Stderr, usageText)
+		return nil
+	}
+	fmt.Fprint(os.Stderr, usageText)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func newFlagSet(name string, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags]\n\n%s\n\nFlags:\n", name, description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args into fs. The flag package has already reported a
+// parse error with the usage, so it is not reported again.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return flag.ErrHelp
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, fmt.Sprintf("unexpected argument %q", fs.Arg(0)))
+	}
+	return nil
+}
+
+func usageError(fs *flag.FlagSet, message string) error {
+	color.Red("[-] " + message)
+	fs.Usage()
+	return flag.ErrHelp
+}
+
+// networkOptions are the flags shared by every command talking to LinkedIn or
+// GitHub.
+type networkOptions struct {
+	proxy    string
+	insecure bool
+	noCache  bool
+	cacheDir string
+	cacheTTL time.Duration
+}
+
+func (o *networkOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.proxy, "proxy", "", "proxy for LinkedIn and GitHub requests (http://, https:// or socks5://), defaults to HTTP_PROXY/HTTPS_PROXY")
+	fs.BoolVar(&o.insecure, "insecure", false, "skip TLS certificate verification, e.g. behind Burp")
+	fs.BoolVar(&o.noCache, "no-cache", false, "do not read or write the GitHub response cache")
+	fs.StringVar(&o.cacheDir, "cache-dir", defaultCacheDir(), "directory of the GitHub response cache")
+	fs.DurationVar(&o.cacheTTL, "cache-ttl", defaultCacheTTL, "how long cached GitHub responses are used before being revalidated")
+}
+
+// setDefaultCacheTTL changes the default of -cache-ttl for a command. It is
+// called after register and before the flags are parsed.
+func (o *networkOptions) setDefaultCacheTTL(fs *flag.FlagSet, ttl time.Duration) {
+	o.cacheTTL = ttl
+	fs.Lookup("cache-ttl").DefValue = ttl.String()
+}
+
+func (o *networkOptions) apply() error {
+	client, err := newHTTPClient(o.proxy, o.insecure)
+	if err != nil {
+		return err
+	}
+	if !o.noCache && o.cacheDir != "" {
+		client.Transport = &cachingTransport{next: client.Transport, dir: o.cacheDir, ttl: o.cacheTTL}
+	}
+	client.Transport = &metricsTransport{next: client.Transport}
+	httpClient = client
+	return nil
+}
+
+// linkedInOptions select the LinkedIn search to fetch employees from.
+type linkedInOptions struct {
+	requestFile     string
+	company         string
+	cookies         string
+	queryID         string
+	employment      string
+	partition       string
+	partitionValues facetValuesFlag
+
+	// request is a captured request sent to mulef serve, used instead of
+	// requestFile.
+	request []byte
+	// ctx, when set, cancels the search, as mulef serve does for a job.
+	ctx context.Context
+}
+
+func (o *linkedInOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.requestFile, "LinkedInRequest", "", "path of the linkedin request file")
+	fs.StringVar(&o.company, "company", "", "LinkedIn company name, ID, URN or page URL to search instead of a captured request")
+	fs.StringVar(&o.cookies, "cookies", "", "LinkedIn session cookies (li_at and JSESSIONID) for -company, defaults to LINKEDIN_COOKIES")
+	fs.StringVar(&o.queryID, "query-id", "", "voyager search queryId for -company, defaults to the captured request's or a built-in one")
+	fs.StringVar(&o.employment, "employment", employmentCurrent, "which employees to look for (current, past, all)")
+	fs.StringVar(&o.partition, "partition", "", "split the LinkedIn search on these facets when it exceeds 1000 results (auto, or comma-separated: geoUrn, function, seniority, school, keywords)")
+	o.partitionValues = facetValuesFlag{}
+	fs.Var(o.partitionValues, "partition-values", "values of a partition facet as facet=value1,value2 (repeatable)")
+}
+
+func (o *linkedInOptions) validate(fs *flag.FlagSet) error {
+	if err := o.check(); err != nil {
+		return usageError(fs, err.Error())
+	}
+	return nil
+}
+
+// check is validate without printing the usage, for the jobs of mulef serve.
+func (o *linkedInOptions) check() error {
+	if o.requestFile == "" && o.request == nil && o.company == "" {
+		return errors.New("Either -LinkedInRequest or -company is required")
+	}
+	switch o.employment {
+	case employmentCurrent, employmentPast, employmentAll:
+	default:
+		return errors.New("Invalid employment, use current, past or all")
+	}
+	if o.partition != "" {
+		if _, err := parsePartitionFacets(o.partition, o.partitionValues); err != nil {
+			return errors.New("Invalid partition: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// fetch runs the LinkedIn search after checking the session.
+func (o *linkedInOptions) fetch() ([]Employee, error) {
+	var facets []partitionFacet
+	if o.partition != "" {
+		var err error
+		if facets, err = parsePartitionFacets(o.partition, o.partitionValues); err != nil {
+			return nil, err
+		}
+	}
+
+	var fromRequest *capturedRequest
+	switch {
+	case o.request != nil:
+		parsed, err := parseCapturedRequest(o.request)
+		if err != nil {
+			return nil, fmt.Errorf("can not read LinkedIn request: %v", err)
+		}
+		fromRequest = &parsed
+	case o.requestFile != "":
+		loaded, err := loadCapturedRequest(o.requestFile)
+		if err != nil {
+			return nil, fmt.Errorf("can not read LinkedIn request: %v", err)
+		}
+		fromRequest = &loaded
+	}
+	captured, err := linkedInSearch(fromRequest, o.company, o.cookies, o.queryID)
+	if err != nil {
+		return nil, err
+	}
+
+	color.Cyan("[+] Checking LinkedIn session")
+	first, err := checkLinkedInSession(captured)
+	if err != nil {
+		return nil, err
+	}
+	captured.firstPage = &first
+
+	color.Cyan("[+] Processing LinkedIn Request")
+	captured.ctx = o.ctx
+	captured.progress = startProgress("LinkedIn", "pages", 0)
+	employees, err := fetchEmployees(captured, o.employment, facets)
+	captured.progress.finish()
+	if err == nil && o.ctx != nil && o.ctx.Err() != nil {
+		// The pages fetched before the cancellation are not worth keeping.
+		return nil, o.ctx.Err()
+	}
+	return employees, err
+}
+
+// githubOptions configure the matching of employees to GitHub accounts.
+type githubOptions struct {
+	mode       string
+	keywords   string
+	token      string
+	tokenFile  string
+	output     string
+	results    string
+	threads    int
+	backend    string
+	database   string
+	engagement string
+
+	// sinks receive every match kept at the end of the run.
+	sinks []Sink
+	// found, when set, is told of every match as soon as it is found, before
+	// the logins found for several employees are assigned.
+	found func(Match)
+	// ctx and progress are passed on to matchEmployees.
+	ctx      context.Context
+	progress func(done int, total int)
+
+	// tokenSource describes where the token was found.
+	tokenSource string
+
+	// profile, when a config file is used, adds the company aliases, domains,
+	// organizations, office locations and scoring weights.
+	profile *Profile
+}
+
+func (o *githubOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.mode, "mode", "", "mode of finding employees (location, keywords)")
+	fs.StringVar(&o.keywords, "keywords", "", "comma-separated list of keywords")
+	fs.StringVar(&o.token, "token", "", "github token, prefer GITHUB_TOKEN or -token-file as flags end up in the shell history")
+	fs.StringVar(&o.tokenFile, "token-file", "", "path of a file holding the github token")
+	fs.StringVar(&o.output, "output", "", "path of the output file")
+	fs.StringVar(&o.results, "results", "", "path of a JSON file to save the matches and their evidence to, for mulef report")
+	fs.IntVar(&o.threads, "threads", 1, "number of employees matched at the same time")
+	fs.StringVar(&o.backend, "backend", backendREST, "GitHub API used to look up candidates (rest, graphql), graphql fetches 25 profiles per request")
+	fs.StringVar(&o.database, "db", "", "path of a SQLite store to save the run to")
+	fs.StringVar(&o.engagement, "engagement", "", "name the run is stored under, defaults to the config profile")
+}
+
+func (o *githubOptions) validate(fs *flag.FlagSet) error {
+	if err := o.check(); err != nil {
+		return usageError(fs, err.Error())
+	}
+	return nil
+}
+
+// check is validate without printing the usage, for the jobs of mulef serve.
+func (o *githubOptions) check() error {
+	switch o.mode {
+	case modeLocation:
+	case modeKeywords:
+		if strings.Trim(o.keywords, ", ") == "" {
+			return errors.New("Keywords flag not specified")
+		}
+	case "":
+		return errors.New("Mode flag not specified")
+	default:
+		return errors.New("Invalid mode")
+	}
+	token, source, err := resolveGitHubToken(o.token, o.tokenFile)
+	if err != nil {
+		return err
+	}
+	o.token, o.tokenSource = token, source
+	if o.threads < 1 {
+		return errors.New("Threads must be at least 1")
+	}
+	if o.backend != backendREST && o.backend != backendGraphQL {
+		return errors.New("Invalid backend, use rest or graphql")
+	}
+	return nil
+}
+
+func (o *githubOptions) matchOptions() matchOptions {
+	var keywords []string
+	for _, keyword := range strings.Split(o.keywords, ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	options := matchOptions{Mode: o.mode, Keywords: keywords, Token: o.token, Threads: o.threads, Backend: o.backend, Context: o.ctx, Progress: o.progress}
+	if o.profile != nil {
+		options.Aliases = o.profile.Aliases
+		options.Domains = o.profile.Domains
+		options.Orgs = o.profile.GitHubOrgs
+		options.Locations = o.profile.Locations
+		options.Weights, _ = scoringWeights(o.profile.Scoring)
+	}
+	return options
+}
+
+// matchRun is what githubOptions.match produced.
+type matchRun struct {
+	Results Results
+	// RunID is the ID of the run in the store, 0 without one.
+	RunID int64
+	// Diff holds the differences to the previous run of the engagement, nil
+	// when there is none.
+	Diff *Diff
+}
+
+// match matches employees, appending every login found to -output and
+// saving the full results to -results and the store. With a store it also
+// compares the run with the previous run of the engagement, if there is one.
+// A cancelled run is not saved.
+func (o *githubOptions) match(employees []Employee) (matchRun, error) {
+	var run matchRun
+	status, err := checkGitHubToken(o.token)
+	if err != nil {
+		return run, err
+	}
+	printGitHubTokenStatus(o.tokenSource, status)
+	noteTokenStatus(status)
+
+	options := o.matchOptions()
+	bar := startProgress("GitHub", "employees", len(employees))
+	if bar != nil {
+		baseline := rateLimitSnapshot()
+		bar.budget = rateLimitBudget
+		bar.eta = func(done int, total int, elapsed time.Duration) time.Duration {
+			eta := paceETA(done, total, elapsed)
+			if wait := rateLimitETA(baseline, done, total-done); wait > eta {
+				eta = wait
+			}
+			return eta
+		}
+		options.Progress = bar.set
+	}
+	results := Results{
+		Engagement: o.engagement,
+		StartedAt:  time.Now(),
+		Mode:       options.Mode,
+		Keywords:   options.Keywords,
+		Weights:    options.Weights,
+		Employees:  employees,
+	}
+
+	written := make(map[string]bool)
+	results.Matches = matchEmployees(employees, options, func(match Match) {
+		if o.found != nil {
+			o.found(match)
+		}
+		// A login found for several employees is written once.
+		if o.output != "" && !written[match.Login] {
+			written[match.Login] = true
+			if err := appendToFile(o.output, match.Login+"\n"); err != nil {
+				color.Red("[-] Can not write to output file: %v", err)
+			}
+		}
+	})
+	bar.finish()
+	if o.ctx != nil && o.ctx.Err() != nil {
+		return run, o.ctx.Err()
+	}
+	results.Matches, results.Discarded = assignMatches(results.Matches)
+	results.FinishedAt = time.Now()
+	countMatches(results.Matches)
+
+	ambiguous := 0
+	for _, match := range results.Matches {
+		if match.Ambiguous {
+			ambiguous++
+			color.Yellow("[!] %s was assigned to %s but also found for %s, review it", match.Login, match.Employee.Name, strings.Join(match.Contenders, ", "))
+		}
+	}
+	if len(results.Discarded) > 0 {
+		color.Yellow("[!] Dropped %d matches of logins assigned to another employee, %d assignments are ambiguous", len(results.Discarded), ambiguous)
+	}
+
+	color.Cyan("[+] Matched %d GitHub accounts for %d employees", len(results.Matches), len(employees))
+	if o.results != "" {
+		if err := saveResults(o.results, results); err != nil {
+			return run, fmt.Errorf("can not save results: %v", err)
+		}
+		color.Green("[*] Saved results to %s", o.results)
+	}
+	if len(o.sinks) > 0 && len(results.Matches) > 0 {
+		// Sinks only hear of the matches the assignment kept.
+		events := make([]Event, len(results.Matches))
+		for i := range results.Matches {
+			events[i] = Event{Type: eventMatch, Time: results.FinishedAt, Engagement: o.engagement, Match: &results.Matches[i]}
+		}
+		sendEvents(options.runContext(), o.sinks, events)
+	}
+	run.Results = results
+	if o.database == "" {
+		return run, nil
+	}
+	db, err := openStore(o.database)
+	if err != nil {
+		return run, fmt.Errorf("can not open the store: %v", err)
+	}
+	defer db.close()
+	if run.RunID, err = db.saveRun(results); err != nil {
+		return run, fmt.Errorf("can not save the run to the store: %v", err)
+	}
+	color.Green("[*] Saved run %d to %s", run.RunID, o.database)
+
+	// Compare with the previous run against the same target, if any.
+	if results.Engagement == "" {
+		return run, nil
+	}
+	previousID, err := db.previousRun(results.Engagement, run.RunID)
+	if errors.Is(err, errNoPreviousRun) {
+		return run, nil
+	}
+	if err != nil {
+		return run, fmt.Errorf("can not find the previous run: %v", err)
+	}
+	previous, err := db.loadRun(previousID)
+	if err != nil {
+		return run, err
+	}
+	diff := diffResults(previous, results)
+	diff.Old, diff.New = fmt.Sprintf("run %d", previousID), fmt.Sprintf("run %d", run.RunID)
+	fmt.Println()
+	printDiff(diff)
+	run.Diff = &diff
+	return run, nil
+}
+
+func runLinkedInFetch(name string, args []string) error {
+	fs := newFlagSet(name, "Fetches the employees of a company from LinkedIn and saves them to a JSON or CSV file.")
+	var linkedIn linkedInOptions
+	var network networkOptions
+	var config configOptions
+	linkedIn.register(fs)
+	network.register(fs)
+	config.register(fs)
+	output := fs.String("output", "", "path of the JSON or CSV file to save the employees to")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := config.apply(fs, "output"); err != nil {
+		return err
+	}
+	if err := linkedIn.validate(fs); err != nil {
+		return err
+	}
+	if *output == "" {
+		return usageError(fs, "Output flag not specified")
+	}
+	if err := network.apply(); err != nil {
+		return err
+	}
+	enableProgress()
+
+	employees, err := linkedIn.fetch()
+	if err != nil {
+		return err
+	}
+	return saveEmployees(*output, employees)
+}
+
+func runGitHubMatch(name string, args []string) error {
+	fs := newFlagSet(name, "Looks for the GitHub accounts of the employees in a JSON or CSV file.")
+	var github githubOptions
+	var network networkOptions
+	var config configOptions
+	var sinks sinkOptions
+	github.register(fs)
+	network.register(fs)
+	config.register(fs)
+	sinks.register(fs)
+	employeesFile := fs.String("employees", "", "path of a CSV or JSON employee list, as saved by mulef linkedin fetch")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := config.apply(fs, "employees"); err != nil {
+		return err
+	}
+	github.profile = config.profile
+	if *employeesFile == "" {
+		return usageError(fs, "Employees flag not specified")
+	}
+	if err := github.validate(fs); err != nil {
+		return err
+	}
+	sinkList, err := sinks.sinks()
+	if err != nil {
+		return usageError(fs, err.Error())
+	}
+	github.sinks = sinkList
+	if err := network.apply(); err != nil {
+		return err
+	}
+	enableProgress()
+
+	employees, err := loadEmployees(*employeesFile)
+	if err != nil {
+		return fmt.Errorf("can not read employees: %v", err)
+	}
+	color.Cyan("[+] Loaded %d employees from %s", len(employees), *employeesFile)
+	_, err = github.match(employees)
+	return err
+}
+
+func runRun(name string, args []string) error {
+	fs := newFlagSet(name, "Fetches the employees of a company from LinkedIn, or reads them with -employees, and looks for their GitHub accounts.")
+	var linkedIn linkedInOptions
+	var github githubOptions
+	var network networkOptions
+	var config configOptions
+	var sinks sinkOptions
+	linkedIn.register(fs)
+	github.register(fs)
+	network.register(fs)
+	config.register(fs)
+	sinks.register(fs)
+	employeesFile := fs.String("employees", "", "path of a CSV or JSON employee list to use instead of LinkedIn")
+	saveEmployeesFile := fs.String("save-employees", "", "save the employee list to this JSON or CSV file, without -mode nothing else is done")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := config.apply(fs, "save-employees"); err != nil {
+		return err
+	}
+	github.profile = config.profile
+	if *employeesFile == "" {
+		if err := linkedIn.validate(fs); err != nil {
+			return err
+		}
+	}
+	if github.mode != "" || *saveEmployeesFile == "" {
+		if err := github.validate(fs); err != nil {
+			return err
+		}
+	}
+	sinkList, err := sinks.sinks()
+	if err != nil {
+		return usageError(fs, err.Error())
+	}
+	github.sinks = sinkList
+	if err := network.apply(); err != nil {
+		return err
+	}
+	enableProgress()
+
+	var employees []Employee
+	if *employeesFile != "" {
+		if employees, err = loadEmployees(*employeesFile); err != nil {
+			return fmt.Errorf("can not read employees: %v", err)
+		}
+		color.Cyan("[+] Loaded %d employees from %s", len(employees), *employeesFile)
+	} else {
+		if employees, err = linkedIn.fetch(); err != nil {
+			return err
+		}
+	}
+
+	if *saveEmployeesFile != "" {
+		if err := saveEmployees(*saveEmployeesFile, employees); err != nil {
+			return err
+		}
+		if github.mode == "" {
+			return nil
+		}
+	}
+	_, err = github.match(employees)
+	return err
+}
+
+func saveEmployees(filename string, employees []Employee) error {
+	saved, err := saveEmployeeList(filename, employees)
+	if err != nil {
+		return fmt.Errorf("can not save employees: %v", err)
+	}
+	color.Green("[*] Saved %d employees to %s", saved, filename)
+	return nil
+}
+
+func runReport(name string, args []string) error {
+	fs := newFlagSet(name, "Summarises the results saved by a matching run with -results.")
+	resultsFile := fs.String("results", "", "path of the results file")
+	htmlFile := fs.String("html", "", "write a self-contained HTML report to this file")
+	embedAvatars := fs.Bool("embed-avatars", false, "embed the avatars in the HTML report instead of linking them")
+	markdownFile := fs.String("markdown", "", "write a Markdown report grouped by confidence tier to this file")
+	thresholds := defaultTierThresholds
+	fs.Float64Var(&thresholds.High, "tier-high", defaultTierThresholds.High, "lowest score of a high confidence match")
+	fs.Float64Var(&thresholds.Medium, "tier-medium", defaultTierThresholds.Medium, "lowest score of a medium confidence match")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *resultsFile == "" {
+		return usageError(fs, "Results flag not specified")
+	}
+	if thresholds.Medium > thresholds.High {
+		return usageError(fs, "Tier-medium can not be above tier-high")
+	}
+
+	results, err := loadResults(*resultsFile)
+	if err != nil {
+		return fmt.Errorf("can not read results: %v", err)
+	}
+	if len(results.Employees) == 0 && len(results.Matches) == 0 {
+		return errors.New("the results file is empty")
+	}
+	if *htmlFile != "" {
+		if err := writeHTMLReport(*htmlFile, results, *embedAvatars); err != nil {
+			return fmt.Errorf("can not write the HTML report: %v", err)
+		}
+		color.Green("[*] Saved the HTML report to %s", *htmlFile)
+	}
+	if *markdownFile != "" {
+		if err := writeMarkdownReport(*markdownFile, results, thresholds); err != nil {
+			return fmt.Errorf("can not write the Markdown report: %v", err)
+		}
+		color.Green("[*] Saved the Markdown report to %s", *markdownFile)
+	}
+	if *htmlFile == "" && *markdownFile == "" {
+		printReport(results)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunCommand(t *testing.T) {
+	dir := t.TempDir()
+	employeesFile := filepath.Join(dir, "employees.json")
+	employees := []Employee{{Name: "Alice", Location: "Berlin"}, {Name: "Bob"}}
+	if _, err := saveEmployeeList(employeesFile, employees); err != nil {
+		t.Fatal(err)
+	}
+	resultsFile := filepath.Join(dir, "results.json")
+	results := Results{
+		StartedAt: time.Now(),
+		Mode:      modeLocation,
+		Employees: employees,
+		Matches:   []Match{{Employee: employees[0], Login: "alice", Evidence: []Evidence{{Kind: evidenceLocation, Detail: "Berlin"}}}},
+	}
+	if err := saveResults(resultsFile, results); err != nil {
+		t.Fatal(err)
+	}
+	converted := filepath.Join(dir, "employees.csv")
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr error
+		anyErr  bool
+	}{
+		{name: "no command runs run", args: nil, wantErr: flag.ErrHelp},
+		{name: "unknown command", args: []string{"bogus"}, anyErr: true},
+		{name: "linkedin without subcommand", args: []string{"linkedin"}, wantErr: flag.ErrHelp},
+		{name: "github match without employees", args: []string{"github", "match", "-mode", "location"}, wantErr: flag.ErrHelp},
+		{name: "run with invalid mode", args: []string{"run", "-employees", employeesFile, "-mode", "bogus"}, wantErr: flag.ErrHelp},
+		{name: "run with no threads", args: []string{"run", "-employees", employeesFile, "-mode", "location", "-token", "t", "-threads", "0"}, wantErr: flag.ErrHelp},
+		{name: "run with stray argument", args: []string{"run", "extra"}, wantErr: flag.ErrHelp},
+		{name: "convert employee list", args: []string{"run", "-employees", employeesFile, "-save-employees", converted}},
+		{name: "report without results", args: []string{"report"}, wantErr: flag.ErrHelp},
+		{name: "report", args: []string{"report", "-results", resultsFile}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runCommand(tt.args)
+			switch {
+			case tt.anyErr:
+				if err == nil || errors.Is(err, flag.ErrHelp) {
+					t.Errorf("runCommand() = %v, want an error", err)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Errorf("runCommand() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	got, err := loadEmployees(converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, employees) {
+		t.Errorf("converted employees = %+v, want %+v", got, employees)
+	}
+}
+
+func TestGitHubOptionsMatchKeywords(t *testing.T) {
+	var queries []string
+	withHTTPClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		q := req.URL.Query().Get("q")
+		switch req.URL.Path {
+		case "/rate_limit":
+			return stubResponse(http.StatusOK, `{"resources":{"core":{"limit":5000,"remaining":5000},"search":{"limit":30,"remaining":30}}}`), nil
+		case "/search/users":
+			if q == "Alice Smith" {
+				return stubResponse(http.StatusOK, `{"total_count":1,"items":[{"login":"alice"}]}`), nil
+			}
+			return stubResponse(http.StatusOK, `{"total_count":0,"items":[]}`), nil
+		case "/users/alice":
+			return stubResponse(http.StatusOK, `{"login":"alice"}`), nil
+		case "/users/alice/repos":
+			return stubResponse(http.StatusOK, `[]`), nil
+		case "/search/code":
+			queries = append(queries, q)
+			if q == "user:alice acme corp" {
+				return stubResponse(http.StatusOK, `{"total_count":1,"items":[{"path":"README.md"}]}`), nil
+			}
+			return stubResponse(http.StatusOK, `{"total_count":0,"items":[]}`), nil
+		}
+		return stubResponse(http.StatusNotFound, `{}`), nil
+	}))
+	dir := t.TempDir()
+	output := filepath.Join(dir, "found.txt")
+	resultsFile := filepath.Join(dir, "results.json")
+
+	options := githubOptions{mode: modeKeywords, keywords: "dotcom, ,acme corp,", token: "t", output: output, results: resultsFile}
+	if _, err := options.match([]Employee{{Name: "Alice Smith"}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"user:alice dotcom", "user:alice acme corp"}; !reflect.DeepEqual(queries, want) {
+		t.Errorf("code searches = %q, want %q", queries, want)
+	}
+	if got, _ := ioutil.ReadFile(output); string(got) != "alice\n" {
+		t.Errorf("found %q, want %q", got, "alice\n")
+	}
+
+	results, err := loadResults(resultsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Evidence{{Kind: evidenceKeywordCode, Keyword: "acme corp", Detail: "keyword in /README.md"}}
+	if len(results.Matches) != 1 || !reflect.DeepEqual(results.Matches[0].Evidence, want) {
+		t.Errorf("matches = %+v, want alice with %+v", results.Matches, want)
+	}
+	if !reflect.DeepEqual(results.Keywords, []string{"dotcom", "acme corp"}) || len(results.Employees) != 1 {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestGitHubOptionsMatchOptions(t *testing.T) {
+	options := githubOptions{mode: modeKeywords, keywords: " go, ,rust,", threads: 4}
+	got := options.matchOptions()
+	if want := []string{"go", "rust"}; !reflect.DeepEqual(got.Keywords, want) {
+		t.Errorf("Keywords = %q, want %q", got.Keywords, want)
+	}
+	if got.Threads != 4 || got.Mode != modeKeywords {
+		t.Errorf("matchOptions() = %+v", got)
+	}
+}
+
+func TestMatchSendsKeptMatches(t *testing.T) {
+	withHTTPClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/rate_limit":
+			return stubResponse(http.StatusOK, `{"resources":{"core":{"limit":5000,"remaining":5000}}}`), nil
+		case "/search/users":
+			// Both employees turn up the same account.
+			return stubResponse(http.StatusOK, `{"total_count":1,"items":[{"login":"asmith"}]}`), nil
+		case "/users/asmith":
+			return stubResponse(http.StatusOK, `{"login":"asmith","location":"Berlin"}`), nil
+		}
+		return stubResponse(http.StatusNotFound, `{}`), nil
+	}))
+	sink := &recordingSink{name: "recording"}
+	o := githubOptions{mode: modeLocation, token: "t", threads: 2, backend: backendREST, engagement: "acme", sinks: []Sink{sink}}
+
+	run, err := o.match([]Employee{{Name: "Alice Smith", Location: "Berlin"}, {Name: "Anna Smith", Location: "Berlin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Results.Matches) != 1 || len(run.Results.Discarded) != 1 {
+		t.Fatalf("kept %d and discarded %d matches, want 1 and 1", len(run.Results.Matches), len(run.Results.Discarded))
+	}
+	if len(sink.events) != 1 {
+		t.Fatalf("sink got %d events, want only the kept match", len(sink.events))
+	}
+	got := sink.events[0]
+	if got.Type != eventMatch || got.Engagement != "acme" || got.Match.Employee.Name != run.Results.Matches[0].Employee.Name || !got.Match.Ambiguous {
+		t.Errorf("event = %+v, match %+v", got, got.Match)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// defaultSearchQueryID is the voyager people search query id known to work
+// at the time of writing. LinkedIn rotates it with its web releases, so it
+// can be overridden with -query-id or taken from a captured request.
+const defaultSearchQueryID = "voyagerSearchDashClusters.b0928897b71bd00a5a7291755dcd64f0"
+
+// linkedInCookiesEnv holds the session cookies when -cookies is not given.
+const linkedInCookiesEnv = "LINKEDIN_COOKIES"
+
+const linkedInUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+var (
+	companyIDPattern      = regexp.MustCompile(`^(?:urn:li:[A-Za-z_]+:)?(\d+)$`)
+	companyURLPattern     = regexp.MustCompile(`linkedin\.com/company/([^/?#]+)`)
+	searchQueryIDPattern  = regexp.MustCompile(`queryId=(voyagerSearchDashClusters\.[0-9a-f]+)`)
+	companyEntityIDSuffix = regexp.MustCompile(`(\d+)$`)
+)
+
+// newLinkedInSession returns a request template carrying nothing but the
+// session cookies and the headers voyager expects alongside them.
+func newLinkedInSession(cookies string) capturedRequest {
+	session := newCapturedRequest()
+	session.Method = http.MethodGet
+	session.addCookies(cookies)
+	session.Header.Set("Csrf-Token", session.cookie("JSESSIONID"))
+	session.Header.Set("Accept", "application/vnd.linkedin.normalized+json+2.1")
+	session.Header.Set("X-Restli-Protocol-Version", "2.0.0")
+	session.Header.Set("X-Li-Lang", "en_US")
+	session.Header.Set("User-Agent", linkedInUserAgent)
+	return session
+}
+
+// sessionCookies finds the LinkedIn cookies to use for a company search: the
+// -cookies flag, then LINKEDIN_COOKIES, then the cookies of a captured request.
+func sessionCookies(cookies string, captured *capturedRequest) (string, error) {
+	if cookies != "" {
+		return cookies, nil
+	}
+	if cookies = os.Getenv(linkedInCookiesEnv); cookies != "" {
+		return cookies, nil
+	}
+	if captured != nil && captured.Header.Get("Cookie") != "" {
+		return captured.Header.Get("Cookie"), nil
+	}
+	return "", errors.New("no LinkedIn session, pass the li_at and JSESSIONID cookies with -cookies or " + linkedInCookiesEnv)
+}
+
+// companySearchRequest builds the people search request for company, which
+// may be a company ID, a company URN, a company page URL or the company's
+// universal name (the slug in linkedin.com/company/<name>).
+func companySearchRequest(company string, cookies string, queryID string) (capturedRequest, error) {
+	session := newLinkedInSession(cookies)
+	if err := checkLinkedInCookies(session); err != nil {
+		return capturedRequest{}, err
+	}
+
+	companyID, err := resolveCompanyID(session, company)
+	if err != nil {
+		return capturedRequest{}, err
+	}
+	if queryID == "" {
+		queryID = defaultSearchQueryID
+	}
+
+	session.URL = linkedInBaseURL + "/voyager/api/graphql?variables=(start:0,origin:COMPANY_PAGE_CANNED_SEARCH,query:(flagshipSearchIntent:SEARCH_SRP,queryParameters:List((key:currentCompany,value:List(" + companyID + ")),(key:resultType,value:List(PEOPLE))),includeFiltersInResponse:false))&queryId=" + queryID
+	return session, nil
+}
+
+// capturedQueryID returns the search query id of a captured request, if any.
+func capturedQueryID(captured capturedRequest) string {
+	if match := searchQueryIDPattern.FindStringSubmatch(captured.URL); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+func resolveCompanyID(session capturedRequest, company string) (string, error) {
+	company = strings.TrimSpace(company)
+	if match := companyIDPattern.FindStringSubmatch(company); match != nil {
+		return match[1], nil
+	}
+	if match := companyURLPattern.FindStringSubmatch(company); match != nil {
+		company = match[1]
+	}
+	if company == "" {
+		return "", errors.New("empty company name")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, linkedInBaseURL+"/voyager/api/organization/companies?decorationId=com.linkedin.voyager.deco.organization.web.WebFullCompanyMain-12&q=universalName&universalName="+url.QueryEscape(company), nil)
+	if err != nil {
+		return "", err
+	}
+	for name, values := range session.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("LinkedIn has no company page named %q, use the name from linkedin.com/company/<name> or the company ID", company)
+	}
+	if err := checkLinkedInResponse(resp, body); err != nil {
+		return "", err
+	}
+
+	var companies struct {
+		Elements []struct {
+			EntityURN string `json:"entityUrn"`
+			Name      string `json:"name"`
+		} `json:"elements"`
+	}
+	if err := json.Unmarshal(body, &companies); err != nil {
+		return "", fmt.Errorf("can not read company lookup: %v: %s", err, snippet(body))
+	}
+	if len(companies.Elements) == 0 {
+		return "", fmt.Errorf("LinkedIn has no company page named %q", company)
+	}
+
+	match := companyEntityIDSuffix.FindStringSubmatch(companies.Elements[0].EntityURN)
+	if match == nil {
+		return "", fmt.Errorf("unexpected company URN %q", companies.Elements[0].EntityURN)
+	}
+	return match[1], nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc lets tests answer requests to fixed hosts such as
+// www.linkedin.com without touching the network.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func stubResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// withHTTPClient swaps the shared client for the duration of a test.
+func withHTTPClient(t *testing.T, transport http.RoundTripper) {
+	saved := httpClient
+	httpClient = &http.Client{Transport: transport}
+	t.Cleanup(func() { httpClient = saved })
+}
+
+func TestResolveCompanyID(t *testing.T) {
+	withHTTPClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Query().Get("universalName") {
+		case "acme":
+			return stubResponse(http.StatusOK, `{"elements":[{"entityUrn":"urn:li:fs_normalized_company:1234","name":"Acme"}]}`), nil
+		case "empty":
+			return stubResponse(http.StatusOK, `{"elements":[]}`), nil
+		default:
+			return stubResponse(http.StatusNotFound, `{}`), nil
+		}
+	}))
+
+	tests := []struct {
+		company string
+		want    string
+		wantErr bool
+	}{
+		{company: "1234", want: "1234"},
+		{company: "urn:li:company:1234", want: "1234"},
+		{company: "urn:li:fsd_company:1234", want: "1234"},
+		{company: "acme", want: "1234"},
+		{company: "https://www.linkedin.com/company/acme/people/", want: "1234"},
+		{company: "empty", wantErr: true},
+		{company: "missing", wantErr: true},
+		{company: " ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.company, func(t *testing.T) {
+			got, err := resolveCompanyID(newLinkedInSession("li_at=x"), tt.company)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveCompanyID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveCompanyID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionCookies(t *testing.T) {
+	captured := newCapturedRequest()
+	captured.addCookies("li_at=captured")
+
+	tests := []struct {
+		name     string
+		flag     string
+		env      string
+		captured *capturedRequest
+		want     string
+		wantErr  bool
+	}{
+		{name: "flag wins", flag: "li_at=flag", env: "li_at=env", captured: &captured, want: "li_at=flag"},
+		{name: "env before capture", env: "li_at=env", captured: &captured, want: "li_at=env"},
+		{name: "captured request", captured: &captured, want: "li_at=captured"},
+		{name: "nothing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(linkedInCookiesEnv, tt.env)
+			got, err := sessionCookies(tt.flag, tt.captured)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sessionCookies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("sessionCookies() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompanySearchRequest(t *testing.T) {
+	captured, err := companySearchRequest("1234", `li_at=x; JSESSIONID="ajax:1"`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(captured.URL, "(key:currentCompany,value:List(1234))") {
+		t.Errorf("URL does not filter on the company: %s", captured.URL)
+	}
+	if got := capturedQueryID(captured); got != defaultSearchQueryID {
+		t.Errorf("capturedQueryID() = %q, want %q", got, defaultSearchQueryID)
+	}
+	if got := captured.Header.Get("Csrf-Token"); got != "ajax:1" {
+		t.Errorf("Csrf-Token = %q", got)
+	}
+	if _, err := companySearchRequest("1234", "li_at=x", ""); err == nil {
+		t.Error("session without JSESSIONID accepted")
+	}
+}
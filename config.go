@@ -0,0 +1,454 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a mulef configuration file: named profiles, usually one per
+// engagement, holding the settings otherwise given as flags.
+type Config struct {
+	DefaultProfile string              `yaml:"default_profile" toml:"default_profile"`
+	Profiles       map[string]*Profile `yaml:"profiles" toml:"profiles"`
+}
+
+// Profile describes one target. String values may reference environment
+// variables as ${NAME} or env:NAME, so tokens and cookies stay out of the file.
+type Profile struct {
+	Company    string             `yaml:"company" toml:"company"`
+	Aliases    []string           `yaml:"aliases" toml:"aliases"`
+	Domains    []string           `yaml:"domains" toml:"domains"`
+	GitHubOrgs []string           `yaml:"github_orgs" toml:"github_orgs"`
+	Keywords   []string           `yaml:"keywords" toml:"keywords"`
+	Mode       string             `yaml:"mode" toml:"mode"`
+	Locations  []string           `yaml:"locations" toml:"locations"`
+	Proxy      string             `yaml:"proxy" toml:"proxy"`
+	Insecure   bool               `yaml:"insecure" toml:"insecure"`
+	LinkedIn   LinkedInProfile    `yaml:"linkedin" toml:"linkedin"`
+	GitHub     GitHubProfile      `yaml:"github" toml:"github"`
+	Output     OutputProfile      `yaml:"output" toml:"output"`
+	Cache      CacheProfile       `yaml:"cache" toml:"cache"`
+	Watch      WatchProfile       `yaml:"watch" toml:"watch"`
+	Sinks      []string           `yaml:"sinks" toml:"sinks"`
+	Webhook    WebhookProfile     `yaml:"webhook" toml:"webhook"`
+	Scoring    map[string]float64 `yaml:"scoring" toml:"scoring"`
+}
+
+type LinkedInProfile struct {
+	Request         string              `yaml:"request" toml:"request"`
+	Cookies         string              `yaml:"cookies" toml:"cookies"`
+	QueryID         string              `yaml:"query_id" toml:"query_id"`
+	Employment      string              `yaml:"employment" toml:"employment"`
+	Partition       string              `yaml:"partition" toml:"partition"`
+	PartitionValues map[string][]string `yaml:"partition_values" toml:"partition_values"`
+}
+
+type GitHubProfile struct {
+	Token     string `yaml:"token" toml:"token"`
+	TokenFile string `yaml:"token_file" toml:"token_file"`
+	Threads   int    `yaml:"threads" toml:"threads"`
+	Backend   string `yaml:"backend" toml:"backend"`
+}
+
+type CacheProfile struct {
+	Disabled bool   `yaml:"disabled" toml:"disabled"`
+	Dir      string `yaml:"dir" toml:"dir"`
+	TTL      string `yaml:"ttl" toml:"ttl"`
+}
+
+type WatchProfile struct {
+	Schedule string `yaml:"schedule" toml:"schedule"`
+	Metrics  string `yaml:"metrics" toml:"metrics"`
+}
+
+type WebhookProfile struct {
+	Template    string `yaml:"template" toml:"template"`
+	Secret      string `yaml:"secret" toml:"secret"`
+	Retries     int    `yaml:"retries" toml:"retries"`
+	ContentType string `yaml:"content_type" toml:"content_type"`
+}
+
+type OutputProfile struct {
+	Logins    string `yaml:"logins" toml:"logins"`
+	Results   string `yaml:"results" toml:"results"`
+	Employees string `yaml:"employees" toml:"employees"`
+	Database  string `yaml:"database" toml:"database"`
+}
+
+func loadConfig(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if strings.EqualFold(filepath.Ext(filename), ".toml") {
+		err = toml.Unmarshal(data, &config)
+	} else {
+		decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if len(config.Profiles) == 0 {
+		return nil, fmt.Errorf("%s: no profiles defined", filename)
+	}
+	return &config, nil
+}
+
+// profile returns the named profile, the default profile when name is empty,
+// or the only profile when there is no default.
+func (c *Config) profile(name string) (*Profile, error) {
+	name, err := c.profileName(name)
+	if err != nil {
+		return nil, err
+	}
+	profile, ok := c.Profiles[name]
+	if !ok || profile == nil {
+		return nil, fmt.Errorf("no profile named %q in the config (%s)", name, strings.Join(c.profileNames(), ", "))
+	}
+	return profile, nil
+}
+
+// profileName resolves an empty name to the profile that would be used.
+func (c *Config) profileName(name string) (string, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		if len(c.Profiles) != 1 {
+			return "", fmt.Errorf("the config has %d profiles and no default_profile, pick one with -profile (%s)", len(c.Profiles), strings.Join(c.profileNames(), ", "))
+		}
+		for only := range c.Profiles {
+			name = only
+		}
+	}
+	return name, nil
+}
+
+func (c *Config) profileNames() []string {
+	var names []string
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var envReferencePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// resolveEnv replaces ${NAME} references in value, or the whole value when it
+// is env:NAME, with the environment variable. Unset variables are an error so
+// a missing token does not silently turn into an empty one.
+func resolveEnv(value string) (string, error) {
+	if strings.HasPrefix(value, "env:") {
+		name := strings.TrimPrefix(value, "env:")
+		resolved, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return resolved, nil
+	}
+
+	var missing []string
+	resolved := envReferencePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		name := envReferencePattern.FindStringSubmatch(reference)[1]
+		resolved, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return resolved
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+// keywords are the profile keywords followed by the company aliases, which
+// are searched for the same way.
+func (p *Profile) keywords() []string {
+	var keywords []string
+	keywords = append(keywords, p.Keywords...)
+	keywords = append(keywords, p.Aliases...)
+	return keywords
+}
+
+// flagValues maps the profile to the flags of a command. employeesFlag is the
+// flag taking the employees file, which differs between commands.
+func (p *Profile) flagValues(employeesFlag string) map[string]string {
+	values := map[string]string{
+		"company":              p.Company,
+		"mode":                 p.Mode,
+		"keywords":             strings.Join(p.keywords(), ","),
+		"proxy":                p.Proxy,
+		"LinkedInRequest":      p.LinkedIn.Request,
+		"cookies":              p.LinkedIn.Cookies,
+		"query-id":             p.LinkedIn.QueryID,
+		"employment":           p.LinkedIn.Employment,
+		"partition":            p.LinkedIn.Partition,
+		"token":                p.GitHub.Token,
+		"token-file":           p.GitHub.TokenFile,
+		"backend":              p.GitHub.Backend,
+		"output":               p.Output.Logins,
+		"results":              p.Output.Results,
+		"db":                   p.Output.Database,
+		"cache-dir":            p.Cache.Dir,
+		"cache-ttl":            p.Cache.TTL,
+		"schedule":             p.Watch.Schedule,
+		"metrics":              p.Watch.Metrics,
+		"webhook-template":     p.Webhook.Template,
+		"webhook-secret":       p.Webhook.Secret,
+		"webhook-content-type": p.Webhook.ContentType,
+	}
+	values[employeesFlag] = p.Output.Employees
+	if p.Insecure {
+		values["insecure"] = "true"
+	}
+	if p.Cache.Disabled {
+		values["no-cache"] = "true"
+	}
+	if p.GitHub.Threads > 0 {
+		values["threads"] = strconv.Itoa(p.GitHub.Threads)
+	}
+	if p.Webhook.Retries > 0 {
+		values["webhook-retries"] = strconv.Itoa(p.Webhook.Retries)
+	}
+	return values
+}
+
+// validate checks the profile the way the commands would, without running
+// anything.
+func (p *Profile) validate() []error {
+	var errs []error
+	check := func(what string, value string) {
+		if _, err := resolveEnv(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", what, err))
+		}
+	}
+	check("company", p.Company)
+	check("proxy", p.Proxy)
+	check("linkedin.request", p.LinkedIn.Request)
+	check("linkedin.cookies", p.LinkedIn.Cookies)
+	check("github.token", p.GitHub.Token)
+	check("github.token_file", p.GitHub.TokenFile)
+	check("cache.dir", p.Cache.Dir)
+	check("output.database", p.Output.Database)
+
+	switch p.Mode {
+	case "", modeLocation:
+	case modeKeywords:
+		if len(p.keywords()) == 0 {
+			errs = append(errs, errors.New("mode keywords needs keywords or aliases"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid mode %q, use location or keywords", p.Mode))
+	}
+	switch p.LinkedIn.Employment {
+	case "", employmentCurrent, employmentPast, employmentAll:
+	default:
+		errs = append(errs, fmt.Errorf("invalid linkedin.employment %q, use current, past or all", p.LinkedIn.Employment))
+	}
+	if p.LinkedIn.Partition != "" {
+		if _, err := parsePartitionFacets(p.LinkedIn.Partition, p.partitionValues()); err != nil {
+			errs = append(errs, fmt.Errorf("linkedin.partition: %v", err))
+		}
+	}
+	if proxy, err := resolveEnv(p.Proxy); err == nil && proxy != "" {
+		if _, err := newHTTPClient(proxy, false); err != nil {
+			errs = append(errs, fmt.Errorf("proxy: %v", err))
+		}
+	}
+	switch p.GitHub.Backend {
+	case "", backendREST, backendGraphQL:
+	default:
+		errs = append(errs, fmt.Errorf("invalid github.backend %q, use rest or graphql", p.GitHub.Backend))
+	}
+	if p.Cache.TTL != "" {
+		if _, err := time.ParseDuration(p.Cache.TTL); err != nil {
+			errs = append(errs, fmt.Errorf("cache.ttl: %v", err))
+		}
+	}
+	if p.Watch.Schedule != "" {
+		if _, err := parseSchedule(p.Watch.Schedule); err != nil {
+			errs = append(errs, fmt.Errorf("watch.schedule: %v", err))
+		}
+	}
+	check("webhook.secret", p.Webhook.Secret)
+	for _, sink := range p.Sinks {
+		check("sinks", sink)
+		if resolved, err := resolveEnv(sink); err == nil {
+			// The template is checked when the sinks are created.
+			if _, err := parseSink(resolved, &sinkOptions{}); err != nil {
+				errs = append(errs, fmt.Errorf("sinks: %v", err))
+			}
+		}
+	}
+	if p.Webhook.Template != "" {
+		if _, err := newWebhookSink("https://localhost", &sinkOptions{template: p.Webhook.Template}); err != nil {
+			errs = append(errs, fmt.Errorf("webhook.template: %v", err))
+		}
+	}
+	if p.Webhook.Retries < 0 {
+		errs = append(errs, errors.New("webhook.retries can not be negative"))
+	}
+	if p.GitHub.Threads < 0 {
+		errs = append(errs, errors.New("github.threads must be at least 1"))
+	}
+	if _, err := scoringWeights(p.Scoring); err != nil {
+		errs = append(errs, fmt.Errorf("scoring: %v", err))
+	}
+	return errs
+}
+
+func (p *Profile) partitionValues() facetValuesFlag {
+	values := facetValuesFlag{}
+	for key, list := range p.LinkedIn.PartitionValues {
+		values.Set(key + "=" + strings.Join(list, ","))
+	}
+	return values
+}
+
+// configOptions are the -config and -profile flags of the commands that can
+// take their settings from a config file.
+type configOptions struct {
+	file    string
+	name    string
+	profile *Profile
+}
+
+func (o *configOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.file, "config", "", "path of a YAML or TOML config file")
+	fs.StringVar(&o.name, "profile", "", "profile of the config file to use, defaults to its default_profile")
+}
+
+// apply loads the selected profile and sets every flag the user did not set
+// on the command line from it, so flags always win over the config.
+func (o *configOptions) apply(fs *flag.FlagSet, employeesFlag string) error {
+	if o.file == "" {
+		if o.name != "" {
+			return errors.New("-profile needs -config")
+		}
+		return nil
+	}
+	config, err := loadConfig(o.file)
+	if err != nil {
+		return err
+	}
+	if o.profile, err = config.profile(o.name); err != nil {
+		return err
+	}
+	if _, err := scoringWeights(o.profile.Scoring); err != nil {
+		return fmt.Errorf("scoring: %v", err)
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	set := func(name string, value string) error {
+		if value == "" || explicit[name] || fs.Lookup(name) == nil {
+			return nil
+		}
+		resolved, err := resolveEnv(value)
+		if err != nil {
+			// Not every setting is needed by every command, so a missing
+			// variable only fails the run when the flag turns out required.
+			color.Yellow("[!] Profile setting for -%s skipped: %v", name, err)
+			return nil
+		}
+		return fs.Set(name, resolved)
+	}
+
+	values := o.profile.flagValues(employeesFlag)
+	// Runs are stored under the profile name unless told otherwise.
+	values["engagement"], _ = config.profileName(o.name)
+	for name, value := range values {
+		if err := set(name, value); err != nil {
+			return err
+		}
+	}
+	if !explicit["sink"] && fs.Lookup("sink") != nil {
+		for _, sink := range o.profile.Sinks {
+			if err := set("sink", sink); err != nil {
+				return err
+			}
+		}
+	}
+	if !explicit["partition-values"] && fs.Lookup("partition-values") != nil {
+		for key, values := range o.profile.LinkedIn.PartitionValues {
+			if err := set("partition-values", key+"="+strings.Join(values, ",")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprint(os.Stderr, "Usage: mulef config validate -config <file> [-profile <name>]\n")
+		return flag.ErrHelp
+	}
+
+	fs := newFlagSet("mulef config validate", "Checks a config file and its profiles without running anything.")
+	var options configOptions
+	options.register(fs)
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if options.file == "" {
+		return usageError(fs, "Config flag not specified")
+	}
+
+	config, err := loadConfig(options.file)
+	if err != nil {
+		return err
+	}
+	names := config.profileNames()
+	if options.name != "" {
+		names = []string{options.name}
+	}
+	if config.DefaultProfile != "" {
+		if _, ok := config.Profiles[config.DefaultProfile]; !ok {
+			return fmt.Errorf("default_profile %q is not defined", config.DefaultProfile)
+		}
+	}
+
+	invalid := 0
+	for _, name := range names {
+		profile, err := config.profile(name)
+		if err != nil {
+			return err
+		}
+		errs := profile.validate()
+		if len(errs) == 0 {
+			color.Green("[*] Profile %s is valid", name)
+			continue
+		}
+		invalid++
+		color.Red("[-] Profile %s has %d problems:", name, len(errs))
+		for _, err := range errs {
+			color.Red("    %v", err)
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d profiles are invalid", invalid, len(names))
+	}
+	return nil
+}
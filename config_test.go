@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolveEnv(t *testing.T) {
+	t.Setenv("MULEF_TEST_TOKEN", "secret")
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "plain", want: "plain"},
+		{value: "env:MULEF_TEST_TOKEN", want: "secret"},
+		{value: "Bearer ${MULEF_TEST_TOKEN}!", want: "Bearer secret!"},
+		{value: "env:MULEF_TEST_MISSING", wantErr: true},
+		{value: "${MULEF_TEST_MISSING}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := resolveEnv(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func writeConfig(t *testing.T, name string, data string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		data     string
+		profile  string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "YAML default profile",
+			filename: "mulef.yaml",
+			data:     "default_profile: acme\nprofiles:\n  acme:\n    company: acme\n  other:\n    company: other\n",
+			want:     "acme",
+		},
+		{
+			name:     "TOML only profile",
+			filename: "mulef.toml",
+			data:     "[profiles.acme]\ncompany = \"acme\"\n",
+			want:     "acme",
+		},
+		{
+			name:     "named profile",
+			filename: "mulef.yaml",
+			data:     "default_profile: acme\nprofiles:\n  acme:\n    company: acme\n  other:\n    company: other\n",
+			profile:  "other",
+			want:     "other",
+		},
+		{
+			name:     "ambiguous profile",
+			filename: "mulef.yaml",
+			data:     "profiles:\n  acme:\n    company: acme\n  other:\n    company: other\n",
+			wantErr:  true,
+		},
+		{
+			name:     "unknown profile",
+			filename: "mulef.yaml",
+			data:     "profiles:\n  acme:\n    company: acme\n",
+			profile:  "other",
+			wantErr:  true,
+		},
+		{
+			name:     "unknown field",
+			filename: "mulef.yaml",
+			data:     "profiles:\n  acme:\n    compnay: acme\n",
+			wantErr:  true,
+		},
+		{
+			name:     "no profiles",
+			filename: "mulef.yaml",
+			data:     "default_profile: acme\n",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := loadConfig(writeConfig(t, tt.filename, tt.data))
+			var profile *Profile
+			if err == nil {
+				profile, err = config.profile(tt.profile)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && profile.Company != tt.want {
+				t.Errorf("company = %q, want %q", profile.Company, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		errs    int
+	}{
+		{name: "valid", profile: Profile{Company: "acme", Mode: modeKeywords, Aliases: []string{"acme"}}},
+		{name: "keywords without keywords", profile: Profile{Mode: modeKeywords}, errs: 1},
+		{name: "invalid mode", profile: Profile{Mode: "bogus"}, errs: 1},
+		{name: "missing env", profile: Profile{GitHub: GitHubProfile{Token: "env:MULEF_TEST_MISSING"}}, errs: 1},
+		{name: "bad proxy", profile: Profile{Proxy: "ftp://proxy"}, errs: 1},
+		{name: "bad scoring", profile: Profile{Scoring: map[string]float64{"bogus": 1}}, errs: 1},
+		{name: "bad partition", profile: Profile{LinkedIn: LinkedInProfile{Partition: "geo", Employment: "former"}}, errs: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := tt.profile.validate(); len(errs) != tt.errs {
+				t.Errorf("validate() = %v, want %d errors", errs, tt.errs)
+			}
+		})
+	}
+}
+
+func TestConfigOptionsApply(t *testing.T) {
+	t.Setenv("MULEF_TEST_TOKEN", "secret")
+	filename := writeConfig(t, "mulef.yaml", `profiles:
+  acme:
+    company: acme
+    mode: keywords
+    keywords: [go]
+    aliases: [acme-corp]
+    linkedin:
+      cookies: ${MULEF_TEST_MISSING}
+      partition_values:
+        geo: ["1", "2"]
+    github:
+      token: env:MULEF_TEST_TOKEN
+      threads: 4
+`)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var config configOptions
+	var linkedIn linkedInOptions
+	var github githubOptions
+	config.register(fs)
+	linkedIn.register(fs)
+	github.register(fs)
+	if err := fs.Parse([]string{"-config", filename, "-mode", "location"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.apply(fs, "employees"); err != nil {
+		t.Fatal(err)
+	}
+
+	if github.mode != modeLocation {
+		t.Errorf("mode = %q, the flag should win over the profile", github.mode)
+	}
+	if github.token != "secret" || github.threads != 4 || github.keywords != "go,acme-corp" {
+		t.Errorf("github options = %+v", github)
+	}
+	if linkedIn.company != "acme" || linkedIn.cookies != "" {
+		t.Errorf("linkedin options = %+v", linkedIn)
+	}
+	if want := []string{"1", "2"}; !reflect.DeepEqual(linkedIn.partitionValues["geoUrn"], want) {
+		t.Errorf("partition values = %v, want %v", linkedIn.partitionValues, want)
+	}
+}
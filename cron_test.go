@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		expression string
+		wantErr    bool
+	}{
+		{expression: "0 3 * * 1"},
+		{expression: "*/15 9-17 * * 1-5"},
+		{expression: "0 0 1,15 * *"},
+		{expression: "@daily"},
+		{expression: "@every 12h"},
+		{expression: "@every 30s", wantErr: true},
+		{expression: "@every soon", wantErr: true},
+		{expression: "0 3 * *", wantErr: true},
+		{expression: "60 * * * *", wantErr: true},
+		{expression: "* 24 * * *", wantErr: true},
+		{expression: "* * 0 * *", wantErr: true},
+		{expression: "* * * 13 *", wantErr: true},
+		{expression: "* * * * 8", wantErr: true},
+		{expression: "5-1 * * * *", wantErr: true},
+		{expression: "*/0 * * * *", wantErr: true},
+		{expression: "a * * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := parseSchedule(tt.expression)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// A Wednesday.
+	after := time.Date(2024, 5, 15, 10, 30, 20, 0, time.UTC)
+	tests := []struct {
+		expression string
+		after      time.Time
+		want       time.Time
+	}{
+		{expression: "* * * * *", after: after, want: time.Date(2024, 5, 15, 10, 31, 0, 0, time.UTC)},
+		{expression: "*/15 * * * *", after: after, want: time.Date(2024, 5, 15, 10, 45, 0, 0, time.UTC)},
+		{expression: "0 3 * * *", after: after, want: time.Date(2024, 5, 16, 3, 0, 0, 0, time.UTC)},
+		{expression: "@hourly", after: after, want: time.Date(2024, 5, 15, 11, 0, 0, 0, time.UTC)},
+		{expression: "0 9 * * 1", after: after, want: time.Date(2024, 5, 20, 9, 0, 0, 0, time.UTC)},
+		{expression: "0 0 * * 7", after: after, want: time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC)},
+		{expression: "@monthly", after: after, want: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{expression: "0 0 31 * *", after: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), want: time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC)},
+		{expression: "0 0 29 2 *", after: after, want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{expression: "@yearly", after: time.Date(2024, 12, 31, 23, 59, 0, 0, time.UTC), want: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Day of month and day of week restricted: either one is enough.
+		{expression: "0 0 20 * 5", after: after, want: time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)},
+		{expression: "0 12 * * *", after: time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC), want: time.Date(2024, 5, 16, 12, 0, 0, 0, time.UTC)},
+		{expression: "@every 90m", after: after, want: after.Add(90 * time.Minute)},
+		{expression: "0 0 31 2 *", after: after, want: time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			s, err := parseSchedule(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("next(%s) = %s, want %s", tt.after, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// MatchChange is a GitHub account matched in both runs whose evidence
+// changed.
+type MatchChange struct {
+	Match    Match      `json:"match"`
+	OldScore float64    `json:"old_score"`
+	Added    []Evidence `json:"added,omitempty"`
+	Removed  []Evidence `json:"removed,omitempty"`
+}
+
+// Diff is what changed between two runs against the same target.
+type Diff struct {
+	Old               string        `json:"old"`
+	New               string        `json:"new"`
+	NewEmployees      []Employee    `json:"new_employees,omitempty"`
+	DepartedEmployees []Employee    `json:"departed_employees,omitempty"`
+	NewMatches        []Match       `json:"new_matches,omitempty"`
+	LostMatches       []Match       `json:"lost_matches,omitempty"`
+	ChangedMatches    []MatchChange `json:"changed_matches,omitempty"`
+}
+
+func (d Diff) empty() bool {
+	return len(d.NewEmployees) == 0 && len(d.DepartedEmployees) == 0 && len(d.NewMatches) == 0 &&
+		len(d.LostMatches) == 0 && len(d.ChangedMatches) == 0
+}
+
+func matchKey(match Match) string {
+	return employeeKey(match.Employee) + "|" + match.Login
+}
+
+func evidenceKey(evidence Evidence) string {
+	return evidence.Kind + "|" + evidence.Keyword + "|" + evidence.Detail + "|" + evidence.URL
+}
+
+// diffResults compares two runs. Employees who left, or are only found as past
+// employees in the newer run, count as departed. Rejected matches are ignored
+// on both sides.
+func diffResults(older Results, newer Results) Diff {
+	var diff Diff
+
+	oldEmployees := make(map[string]Employee)
+	for _, employee := range older.Employees {
+		oldEmployees[employeeKey(employee)] = employee
+	}
+	newEmployees := make(map[string]Employee)
+	for _, employee := range newer.Employees {
+		key := employeeKey(employee)
+		newEmployees[key] = employee
+		previous, ok := oldEmployees[key]
+		switch {
+		case !ok:
+			diff.NewEmployees = append(diff.NewEmployees, employee)
+		case previous.Status != employmentPast && employee.Status == employmentPast:
+			diff.DepartedEmployees = append(diff.DepartedEmployees, employee)
+		}
+	}
+	for _, employee := range older.Employees {
+		if _, ok := newEmployees[employeeKey(employee)]; !ok {
+			diff.DepartedEmployees = append(diff.DepartedEmployees, employee)
+		}
+	}
+
+	oldMatches := make(map[string]Match)
+	for _, match := range older.Matches {
+		if match.Decision != decisionRejected {
+			oldMatches[matchKey(match)] = match
+		}
+	}
+	newMatches := make(map[string]bool)
+	for _, match := range newer.Matches {
+		if match.Decision == decisionRejected {
+			continue
+		}
+		newMatches[matchKey(match)] = true
+		previous, ok := oldMatches[matchKey(match)]
+		if !ok {
+			diff.NewMatches = append(diff.NewMatches, match)
+			continue
+		}
+
+		change := MatchChange{Match: match, OldScore: previous.Score}
+		before := make(map[string]bool)
+		for _, evidence := range previous.Evidence {
+			before[evidenceKey(evidence)] = true
+		}
+		after := make(map[string]bool)
+		for _, evidence := range match.Evidence {
+			after[evidenceKey(evidence)] = true
+			if !before[evidenceKey(evidence)] {
+				change.Added = append(change.Added, evidence)
+			}
+		}
+		for _, evidence := range previous.Evidence {
+			if !after[evidenceKey(evidence)] {
+				change.Removed = append(change.Removed, evidence)
+			}
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			diff.ChangedMatches = append(diff.ChangedMatches, change)
+		}
+	}
+	for _, match := range older.Matches {
+		if match.Decision != decisionRejected && !newMatches[matchKey(match)] {
+			diff.LostMatches = append(diff.LostMatches, match)
+		}
+	}
+	return diff
+}
+
+func printDiff(diff Diff) {
+	color.Cyan("[+] Comparing %s with %s", diff.Old, diff.New)
+	if diff.empty() {
+		color.Green("[*] Nothing changed")
+		return
+	}
+	color.Cyan("[+] %d new employees, %d departed, %d new accounts, %d accounts no longer matched, %d with changed evidence",
+		len(diff.NewEmployees), len(diff.DepartedEmployees), len(diff.NewMatches), len(diff.LostMatches), len(diff.ChangedMatches))
+
+	if len(diff.NewEmployees) > 0 {
+		fmt.Println("\nNew employees:")
+		for _, employee := range diff.NewEmployees {
+			color.Green("    [+] %s", describeEmployee(employee))
+		}
+	}
+	if len(diff.DepartedEmployees) > 0 {
+		fmt.Println("\nDeparted employees:")
+		for _, employee := range diff.DepartedEmployees {
+			color.Red("    [-] %s", describeEmployee(employee))
+		}
+	}
+	if len(diff.NewMatches) > 0 {
+		fmt.Println("\nNewly matched GitHub accounts:")
+		for _, match := range diff.NewMatches {
+			color.Green("    [+] %s %s for %s (score %g)", match.Login, match.ProfileURL, match.Employee.Name, match.Score)
+			for _, evidence := range match.Evidence {
+				fmt.Printf("        %s: %s %s\n", evidence.Kind, evidence.Detail, evidence.URL)
+			}
+		}
+	}
+	if len(diff.LostMatches) > 0 {
+		fmt.Println("\nAccounts no longer matched:")
+		for _, match := range diff.LostMatches {
+			color.Red("    [-] %s for %s", match.Login, match.Employee.Name)
+		}
+	}
+	if len(diff.ChangedMatches) > 0 {
+		fmt.Println("\nAccounts with changed evidence:")
+		for _, change := range diff.ChangedMatches {
+			color.Yellow("    [~] %s for %s (score %g -> %g)", change.Match.Login, change.Match.Employee.Name, change.OldScore, change.Match.Score)
+			for _, evidence := range change.Added {
+				fmt.Printf("        + %s: %s %s\n", evidence.Kind, evidence.Detail, evidence.URL)
+			}
+			for _, evidence := range change.Removed {
+				fmt.Printf("        - %s: %s %s\n", evidence.Kind, evidence.Detail, evidence.URL)
+			}
+		}
+	}
+}
+
+func describeEmployee(employee Employee) string {
+	details := []string{}
+	for _, detail := range []string{employee.Title, employee.Location} {
+		if detail != "" {
+			details = append(details, detail)
+		}
+	}
+	if len(details) == 0 {
+		return employee.Name
+	}
+	return employee.Name + " (" + strings.Join(details, ", ") + ")"
+}
+
+// loadRunArgument reads a run given on the command line, either a results
+// file or the ID of a run in the store.
+func loadRunArgument(db *store, argument string) (Results, string, error) {
+	if _, err := os.Stat(argument); err == nil {
+		results, err := loadResults(argument)
+		return results, argument, err
+	}
+	id, err := strconv.ParseInt(argument, 10, 64)
+	if err != nil {
+		return Results{}, "", fmt.Errorf("%s is neither a results file nor a run ID", argument)
+	}
+	if db == nil {
+		return Results{}, "", fmt.Errorf("run %d needs -db", id)
+	}
+	results, err := db.loadRun(id)
+	return results, fmt.Sprintf("run %d", id), err
+}
+
+func runDiff(name string, args []string) error {
+	fs := newFlagSet(name+" [old run] [new run]", "Compares two runs, given as results files or run IDs in the store. With one run it is compared with the run before it in the store, without any the latest two runs of -engagement are compared.")
+	database := fs.String("db", "", "path of the SQLite store")
+	engagement := fs.String("engagement", "", "engagement whose runs are compared")
+	jsonFile := fs.String("json", "", "also write the differences as JSON to this file")
+	if err := fs.Parse(args); err != nil {
+		return flag.ErrHelp
+	}
+	if fs.NArg() > 2 {
+		return usageError(fs, "at most two runs can be compared")
+	}
+	if fs.NArg() < 2 && *database == "" {
+		return usageError(fs, "Db flag not specified, it is needed to find the previous run")
+	}
+
+	var db *store
+	if *database != "" {
+		var err error
+		if db, err = openStore(*database); err != nil {
+			return err
+		}
+		defer db.close()
+	}
+
+	var older, newer Results
+	var olderName, newerName string
+	var err error
+	switch fs.NArg() {
+	case 2:
+		if older, olderName, err = loadRunArgument(db, fs.Arg(0)); err != nil {
+			return err
+		}
+		if newer, newerName, err = loadRunArgument(db, fs.Arg(1)); err != nil {
+			return err
+		}
+	case 1:
+		if newer, newerName, err = loadRunArgument(db, fs.Arg(0)); err != nil {
+			return err
+		}
+		before, _ := strconv.ParseInt(fs.Arg(0), 10, 64)
+		if before == 0 {
+			// A results file is newer than everything stored.
+			before = 1 << 62
+		}
+		id, err := db.previousRun(valueOr(*engagement, newer.Engagement), before)
+		if err != nil {
+			return err
+		}
+		if older, err = db.loadRun(id); err != nil {
+			return err
+		}
+		olderName = fmt.Sprintf("run %d", id)
+	default:
+		newID, err := db.previousRun(*engagement, 0)
+		if err != nil {
+			return err
+		}
+		oldID, err := db.previousRun(*engagement, newID)
+		if err != nil {
+			return err
+		}
+		if older, err = db.loadRun(oldID); err != nil {
+			return err
+		}
+		if newer, err = db.loadRun(newID); err != nil {
+			return err
+		}
+		olderName, newerName = fmt.Sprintf("run %d", oldID), fmt.Sprintf("run %d", newID)
+	}
+
+	diff := diffResults(older, newer)
+	diff.Old, diff.New = olderName, newerName
+	printDiff(diff)
+	if *jsonFile != "" {
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*jsonFile, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+		color.Green("[*] Saved the differences to %s", *jsonFile)
+	}
+	return nil
+}
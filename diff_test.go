@@ -0,0 +1,153 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDiffResults(t *testing.T) {
+	alice := Employee{Name: "Alice", Location: "Berlin", Status: employmentCurrent}
+	bob := Employee{Name: "Bob", Location: "Paris", Status: employmentCurrent}
+	carol := Employee{Name: "Carol", Location: "Rome", Status: employmentCurrent}
+	past := func(e Employee) Employee { e.Status = employmentPast; return e }
+	location := Evidence{Kind: evidenceLocation, Detail: "Berlin"}
+	org := Evidence{Kind: evidenceOrgMember, Detail: "acme"}
+
+	tests := []struct {
+		name        string
+		older       Results
+		newer       Results
+		newEmp      []string
+		departed    []string
+		newMatches  []string
+		lostMatches []string
+		changed     []MatchChange
+	}{
+		{
+			name:  "nothing changed",
+			older: Results{Employees: []Employee{alice}, Matches: []Match{{Employee: alice, Login: "alice", Evidence: []Evidence{location}}}},
+			newer: Results{Employees: []Employee{alice}, Matches: []Match{{Employee: alice, Login: "alice", Evidence: []Evidence{location}}}},
+		},
+		{
+			name:     "employees joined and left",
+			older:    Results{Employees: []Employee{alice, bob}},
+			newer:    Results{Employees: []Employee{alice, carol}},
+			newEmp:   []string{"Carol"},
+			departed: []string{"Bob"},
+		},
+		{
+			name:     "employee now listed as past",
+			older:    Results{Employees: []Employee{alice, bob}},
+			newer:    Results{Employees: []Employee{alice, past(bob)}},
+			departed: []string{"Bob"},
+		},
+		{
+			name:        "matches found and lost",
+			older:       Results{Employees: []Employee{alice, bob}, Matches: []Match{{Employee: alice, Login: "alice"}}},
+			newer:       Results{Employees: []Employee{alice, bob}, Matches: []Match{{Employee: bob, Login: "bob"}}},
+			newMatches:  []string{"bob"},
+			lostMatches: []string{"alice"},
+		},
+		{
+			name:        "rejected matches are ignored",
+			older:       Results{Employees: []Employee{alice}, Matches: []Match{{Employee: alice, Login: "old", Decision: decisionRejected}}},
+			newer:       Results{Employees: []Employee{alice}, Matches: []Match{{Employee: alice, Login: "new", Decision: decisionRejected}}},
+			newMatches:  nil,
+			lostMatches: nil,
+		},
+		{
+			name:  "evidence changed",
+			older: Results{Employees: []Employee{alice}, Matches: []Match{{Employee: alice, Login: "alice", Score: 1, Evidence: []Evidence{location}}}},
+			newer: Results{Employees: []Employee{alice}, Matches: []Match{{Employee: alice, Login: "alice", Score: 5, Evidence: []Evidence{org}}}},
+			changed: []MatchChange{{
+				Match:    Match{Employee: alice, Login: "alice", Score: 5, Evidence: []Evidence{org}},
+				OldScore: 1,
+				Added:    []Evidence{org},
+				Removed:  []Evidence{location},
+			}},
+		},
+	}
+	names := func(employees []Employee) []string {
+		var names []string
+		for _, employee := range employees {
+			names = append(names, employee.Name)
+		}
+		return names
+	}
+	logins := func(matches []Match) []string {
+		var logins []string
+		for _, match := range matches {
+			logins = append(logins, match.Login)
+		}
+		return logins
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := diffResults(tt.older, tt.newer)
+			if got := names(diff.NewEmployees); !reflect.DeepEqual(got, tt.newEmp) {
+				t.Errorf("new employees = %v, want %v", got, tt.newEmp)
+			}
+			if got := names(diff.DepartedEmployees); !reflect.DeepEqual(got, tt.departed) {
+				t.Errorf("departed employees = %v, want %v", got, tt.departed)
+			}
+			if got := logins(diff.NewMatches); !reflect.DeepEqual(got, tt.newMatches) {
+				t.Errorf("new matches = %v, want %v", got, tt.newMatches)
+			}
+			if got := logins(diff.LostMatches); !reflect.DeepEqual(got, tt.lostMatches) {
+				t.Errorf("lost matches = %v, want %v", got, tt.lostMatches)
+			}
+			if !reflect.DeepEqual(diff.ChangedMatches, tt.changed) {
+				t.Errorf("changed matches = %+v, want %+v", diff.ChangedMatches, tt.changed)
+			}
+			wantEmpty := tt.newEmp == nil && tt.departed == nil && tt.newMatches == nil && tt.lostMatches == nil && tt.changed == nil
+			if diff.empty() != wantEmpty {
+				t.Errorf("empty() = %v, want %v", diff.empty(), wantEmpty)
+			}
+		})
+	}
+}
+
+func TestLoadRunArgument(t *testing.T) {
+	dir := t.TempDir()
+	resultsFile := filepath.Join(dir, "results.json")
+	if err := saveResults(resultsFile, Results{Mode: modeLocation}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := openStore(filepath.Join(dir, "mulef.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.close()
+	runID, err := db.saveRun(Results{Mode: modeKeywords, StartedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		db       *store
+		argument string
+		wantMode string
+		wantErr  bool
+	}{
+		{name: "results file", argument: resultsFile, wantMode: modeLocation},
+		{name: "run ID", db: db, argument: strconv.FormatInt(runID, 10), wantMode: modeKeywords},
+		{name: "run ID without store", argument: strconv.FormatInt(runID, 10), wantErr: true},
+		{name: "missing run", db: db, argument: strconv.FormatInt(runID+1, 10), wantErr: true},
+		{name: "neither", db: db, argument: filepath.Join(dir, "missing.json"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, _, err := loadRunArgument(tt.db, tt.argument)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadRunArgument() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if results.Mode != tt.wantMode {
+				t.Errorf("mode = %q, want %q", results.Mode, tt.wantMode)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Values of -employment and of Employee.Status.
+const (
+	employmentCurrent = "current"
+	employmentPast    = "past"
+	employmentAll     = "all"
+)
+
+// pastCompanySearch turns a search for a company's current employees into one
+// for its former employees, by moving the company to the pastCompany facet.
+func pastCompanySearch(captured capturedRequest) (capturedRequest, error) {
+	if !strings.Contains(captured.URL, "(key:currentCompany,value:") {
+		return capturedRequest{}, errors.New("the LinkedIn search has no currentCompany filter to look up past employees with, capture the search from the company page or use -company")
+	}
+	past := captured
+	past.firstPage = nil
+	past.URL = strings.Replace(captured.URL, "(key:currentCompany,value:", "(key:pastCompany,value:", 1)
+	past.URL = strings.Replace(past.URL, "origin:COMPANY_PAGE_CANNED_SEARCH", "origin:FACETED_SEARCH", 1)
+	return past, nil
+}
+
+// fetchEmployees runs the company search for the current employees, the past
+// ones or both, and tags every employee accordingly. Someone found in both
+// searches is reported once, as a current employee.
+func fetchEmployees(captured capturedRequest, employment string, facets []partitionFacet) ([]Employee, error) {
+	fetch := func(search capturedRequest, status string) []Employee {
+		var employees []Employee
+		if len(facets) > 0 {
+			employees = fetchPartitionedEmployees(search, facets)
+		} else {
+			employees = fetchLinkedInEmployees(search, searchSlice{})
+		}
+		for i := range employees {
+			employees[i].Status = status
+		}
+		return employees
+	}
+
+	switch employment {
+	case employmentCurrent, "":
+		return fetch(captured, employmentCurrent), nil
+	case employmentPast, employmentAll:
+	default:
+		return nil, fmt.Errorf("invalid employment %q, use current, past or all", employment)
+	}
+
+	past, err := pastCompanySearch(captured)
+	if err != nil {
+		return nil, err
+	}
+	if employment == employmentPast {
+		return fetch(past, employmentPast), nil
+	}
+
+	employees := fetch(captured, employmentCurrent)
+	seen := make(map[string]bool)
+	for _, employee := range employees {
+		seen[employeeKey(employee)] = true
+	}
+	for _, employee := range fetch(past, employmentPast) {
+		if !seen[employeeKey(employee)] {
+			employees = append(employees, employee)
+		}
+	}
+	return employees, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// searchResponseJSON renders a voyager people search page holding people.
+func searchResponseJSON(start int, total int, people ...Employee) string {
+	type text struct {
+		Text string `json:"text"`
+	}
+	type entity struct {
+		TrackingURN       string `json:"trackingUrn"`
+		Title             text   `json:"title"`
+		SecondarySubtitle text   `json:"secondarySubtitle"`
+	}
+	var page struct {
+		Data struct {
+			Data struct {
+				Clusters struct {
+					Paging struct {
+						Count int `json:"count"`
+						Start int `json:"start"`
+						Total int `json:"total"`
+					} `json:"paging"`
+				} `json:"searchDashClustersByAll"`
+			} `json:"data"`
+		} `json:"data"`
+		Included []entity `json:"included"`
+	}
+	page.Data.Data.Clusters.Paging.Count = 10
+	page.Data.Data.Clusters.Paging.Start = start
+	page.Data.Data.Clusters.Paging.Total = total
+	for _, person := range people {
+		page.Included = append(page.Included, entity{
+			TrackingURN:       person.URN,
+			Title:             text{person.Name},
+			SecondarySubtitle: text{person.Location},
+		})
+	}
+	data, _ := json.Marshal(page)
+	return string(data)
+}
+
+func TestPastCompanySearch(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "company page search",
+			url:  "variables=(start:0,origin:COMPANY_PAGE_CANNED_SEARCH,query:(queryParameters:List((key:currentCompany,value:List(1)))))",
+			want: "variables=(start:0,origin:FACETED_SEARCH,query:(queryParameters:List((key:pastCompany,value:List(1)))))",
+		},
+		{
+			name:    "no company filter",
+			url:     "variables=(start:0,query:(keywords:acme))",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pastCompanySearch(capturedRequest{URL: tt.url})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pastCompanySearch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.URL != tt.want {
+				t.Errorf("pastCompanySearch() = %q, want %q", got.URL, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchEmployees(t *testing.T) {
+	alice := Employee{URN: "urn:li:member:1", Name: "Alice", Location: "Berlin"}
+	bob := Employee{URN: "urn:li:member:2", Name: "Bob", Location: "Paris"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.RawQuery, "pastCompany") {
+			w.Write([]byte(searchResponseJSON(0, 2, alice, bob)))
+			return
+		}
+		w.Write([]byte(searchResponseJSON(0, 1, alice)))
+	}))
+	defer server.Close()
+
+	captured := capturedRequest{
+		Method: http.MethodGet,
+		URL:    server.URL + "/voyager/api/graphql?variables=(start:0,query:(queryParameters:List((key:currentCompany,value:List(1)))))",
+		Header: make(http.Header),
+	}
+	current := func(e Employee) Employee { e.Status = employmentCurrent; return e }
+	past := func(e Employee) Employee { e.Status = employmentPast; return e }
+
+	tests := []struct {
+		employment string
+		want       []Employee
+		wantErr    bool
+	}{
+		{employment: "", want: []Employee{current(alice)}},
+		{employment: employmentPast, want: []Employee{past(alice), past(bob)}},
+		{employment: employmentAll, want: []Employee{current(alice), past(bob)}},
+		{employment: "former", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.employment, func(t *testing.T) {
+			got, err := fetchEmployees(captured, tt.employment, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchEmployees() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fetchEmployees() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchEmployeesReusesFirstPage(t *testing.T) {
+	alice := Employee{URN: "urn:li:member:1", Name: "Alice", Location: "Berlin"}
+	tests := []struct {
+		employment string
+		// wantRequests is the number of pages fetched after the preflight.
+		wantRequests int
+	}{
+		{employment: employmentCurrent, wantRequests: 0},
+		{employment: employmentAll, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.employment, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Write([]byte(searchResponseJSON(0, 1, alice)))
+			}))
+			defer server.Close()
+
+			captured := newCapturedRequest()
+			captured.Method = http.MethodGet
+			captured.URL = server.URL + "/voyager/api/graphql?variables=(start:0,query:(queryParameters:List((key:currentCompany,value:List(1)))))"
+			captured.addCookies(`li_at=x; JSESSIONID="ajax:1"`)
+			captured.Header.Set("Csrf-Token", "ajax:1")
+
+			first, err := checkLinkedInSession(captured)
+			if err != nil {
+				t.Fatal(err)
+			}
+			captured.firstPage = &first
+			requests = 0
+			employees, err := fetchEmployees(captured, tt.employment, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(employees) != 1 || employees[0].Status != employmentCurrent {
+				t.Errorf("fetchEmployees() = %v, want Alice as a current employee", employees)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("fetchEmployees() fetched %d pages after the preflight, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
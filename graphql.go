@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// GitHub backends for looking up candidate accounts.
+const (
+	backendREST    = "rest"
+	backendGraphQL = "graphql"
+)
+
+// graphQLBatchSize is how many logins go into one GraphQL query. Each login
+// is an aliased user field, and GitHub limits the nodes a query may touch.
+const graphQLBatchSize = 25
+
+const githubGraphQLURL = "https://api.github.com/graphql"
+
+// githubCandidate is a GitHub account found by the user search together with
+// what is known about it. The REST backend fills Repos and Orgs lazily, the
+// GraphQL backend fetches everything up front.
+type githubCandidate struct {
+	User                githubUserInfo
+	Repos               string
+	ReposFetched        bool
+	Orgs                []string
+	OrgsFetched         bool
+	Social              []string
+	CommitEmails        []githubCommitEmail
+	CommitEmailsFetched bool
+}
+
+// repos returns the text the keyword search looks through for repositories,
+// fetching it over REST when the backend did not. When that fails the
+// repositories are reported and left out of the search.
+func (c *githubCandidate) repos(ctx context.Context, token string) string {
+	if !c.ReposFetched {
+		repos, err := getUserReposDetails(ctx, c.User.Login, token)
+		if err != nil {
+			color.Red("[-] Can not get the repositories of %s: %v", c.User.Login, err)
+			return ""
+		}
+		c.Repos, c.ReposFetched = repos, true
+	}
+	return c.Repos
+}
+
+// commitEmails returns the author emails of the account's recent public
+// commits, fetched over REST by both backends. When that fails the commits
+// are reported and left out.
+func (c *githubCandidate) commitEmails(ctx context.Context, token string) []githubCommitEmail {
+	if !c.CommitEmailsFetched {
+		emails, err := getUserCommitEmails(ctx, c.User.Login, token)
+		if err != nil {
+			color.Red("[-] Can not get the commits of %s: %v", c.User.Login, err)
+			return nil
+		}
+		c.CommitEmails, c.CommitEmailsFetched = emails, true
+	}
+	return c.CommitEmails
+}
+
+// isOrgMember reports whether the account publicly belongs to org, asking
+// GitHub only when the organizations were not fetched with the profile.
+func (c *githubCandidate) isOrgMember(ctx context.Context, token string, org string) (bool, error) {
+	if !c.OrgsFetched {
+		return isPublicOrgMember(ctx, token, org, c.User.Login)
+	}
+	for _, login := range c.Orgs {
+		if strings.EqualFold(login, org) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fetchCandidates looks up the given logins with the selected backend.
+// Logins GitHub does not know as users, organizations for instance, are left
+// out.
+func fetchCandidates(logins []string, options matchOptions) []*githubCandidate {
+	var candidates []*githubCandidate
+	if options.Backend == backendGraphQL {
+		for start := 0; start < len(logins); start += graphQLBatchSize {
+			end := start + graphQLBatchSize
+			if end > len(logins) {
+				end = len(logins)
+			}
+			batch, err := fetchGraphQLCandidates(options.runContext(), options.Token, logins[start:end])
+			if err != nil {
+				color.Red("[-] Can not get user information: %v", err)
+				continue
+			}
+			candidates = append(candidates, batch...)
+		}
+		return candidates
+	}
+
+	for _, login := range logins {
+		userInformaiton, err := getGithubUser(options.runContext(), options.Token, login)
+		if err != nil {
+			color.Red("[-] Can not get user information")
+			continue
+		}
+		candidates = append(candidates, &githubCandidate{User: userInformaiton})
+	}
+	return candidates
+}
+
+// graphQLUserFields is queried for every login of a batch.
+const graphQLUserFields = `{
+    login name location company email bio websiteUrl twitterUsername url avatarUrl createdAt
+    organizations(first: 50) { nodes { login } }
+    pinnedItems(first: 6, types: REPOSITORY) { nodes { ... on Repository { nameWithOwner description url homepageUrl } } }
+    repositories(first: 30, ownerAffiliations: OWNER, isFork: false, orderBy: {field: STARGAZERS, direction: DESC}) { nodes { nameWithOwner description url homepageUrl } }
+    socialAccounts(first: 10) { nodes { provider url } }
+  }`
+
+type graphQLRepository struct {
+	NameWithOwner string `json:"nameWithOwner"`
+	Description   string `json:"description"`
+	URL           string `json:"url"`
+	HomepageURL   string `json:"homepageUrl"`
+}
+
+type graphQLUser struct {
+	Login           string    `json:"login"`
+	Name            string    `json:"name"`
+	Location        string    `json:"location"`
+	Company         string    `json:"company"`
+	Email           string    `json:"email"`
+	Bio             string    `json:"bio"`
+	WebsiteURL      string    `json:"websiteUrl"`
+	TwitterUsername string    `json:"twitterUsername"`
+	URL             string    `json:"url"`
+	AvatarURL       string    `json:"avatarUrl"`
+	CreatedAt       time.Time `json:"createdAt"`
+	Organizations   struct {
+		Nodes []struct {
+			Login string `json:"login"`
+		} `json:"nodes"`
+	} `json:"organizations"`
+	PinnedItems struct {
+		Nodes []graphQLRepository `json:"nodes"`
+	} `json:"pinnedItems"`
+	Repositories struct {
+		Nodes []graphQLRepository `json:"nodes"`
+	} `json:"repositories"`
+	SocialAccounts struct {
+		Nodes []struct {
+			Provider string `json:"provider"`
+			URL      string `json:"url"`
+		} `json:"nodes"`
+	} `json:"socialAccounts"`
+}
+
+// graphQLBatchQuery builds one query asking for every login under an alias,
+// u0 for the first login, u1 for the second and so on.
+func graphQLBatchQuery(logins []string) string {
+	var query strings.Builder
+	query.WriteString("query {\n")
+	for i, login := range logins {
+		fmt.Fprintf(&query, "  u%d: user(login: %s) %s\n", i, strconv.Quote(login), graphQLUserFields)
+	}
+	query.WriteString("}")
+	return query.String()
+}
+
+// fetchGraphQLCandidates fetches the profiles, organizations, pinned and top
+// repositories and social accounts of logins with a single GraphQL request.
+func fetchGraphQLCandidates(ctx context.Context, token string, logins []string) ([]*githubCandidate, error) {
+	payload, err := json.Marshal(map[string]string{"query": graphQLBatchQuery(logins)})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", githubGraphQLURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	githubPause(ctx, resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub answered the GraphQL query with HTTP %d: %s", resp.StatusCode, snippet(body))
+	}
+
+	var result struct {
+		Data   map[string]*graphQLUser `json:"data"`
+		Errors []struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("can not read the GraphQL response: %v", err)
+	}
+	// Logins of organizations come back as NOT_FOUND errors next to the data
+	// of the other aliases, only a response without data means the query
+	// itself failed.
+	if result.Data == nil && len(result.Errors) > 0 {
+		return nil, errors.New(result.Errors[0].Message)
+	}
+
+	var candidates []*githubCandidate
+	for i := range logins {
+		user := result.Data["u"+strconv.Itoa(i)]
+		if user == nil {
+			continue
+		}
+		candidates = append(candidates, user.candidate())
+	}
+	return candidates, nil
+}
+
+// candidate converts the GraphQL user to the shape the REST backend produces,
+// so matching does not care which backend was used.
+func (u *graphQLUser) candidate() *githubCandidate {
+	candidate := &githubCandidate{
+		User: githubUserInfo{
+			Login:           u.Login,
+			AvatarURL:       u.AvatarURL,
+			HTMLURL:         u.URL,
+			Type:            "User",
+			Name:            u.Name,
+			Blog:            u.WebsiteURL,
+			Location:        u.Location,
+			Bio:             u.Bio,
+			TwitterUsername: u.TwitterUsername,
+			CreatedAt:       u.CreatedAt,
+		},
+		ReposFetched: true,
+		OrgsFetched:  true,
+	}
+	// REST returns null for an unset company or email, keep it that way.
+	if u.Company != "" {
+		candidate.User.Company = u.Company
+	}
+	if u.Email != "" {
+		candidate.User.Email = u.Email
+	}
+
+	repos := append(u.PinnedItems.Nodes, u.Repositories.Nodes...)
+	if data, err := json.Marshal(repos); err == nil {
+		candidate.Repos = string(data)
+	}
+	for _, org := range u.Organizations.Nodes {
+		candidate.Orgs = append(candidate.Orgs, org.Login)
+	}
+	for _, account := range u.SocialAccounts.Nodes {
+		candidate.Social = append(candidate.Social, account.URL)
+	}
+	return candidate
+}
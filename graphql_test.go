@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGraphQLBatchQuery(t *testing.T) {
+	query := graphQLBatchQuery([]string{"alice", `b"ob`})
+	for _, want := range []string{`u0: user(login: "alice")`, `u1: user(login: "b\"ob")`} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query does not contain %s:\n%s", want, query)
+		}
+	}
+}
+
+func TestFetchGraphQLCandidates(t *testing.T) {
+	withHTTPClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		var payload struct {
+			Query string `json:"query"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil || !strings.Contains(payload.Query, "u1: user") {
+			return stubResponse(http.StatusBadRequest, `{}`), nil
+		}
+		if req.Header.Get("Authorization") != "Bearer token" {
+			return stubResponse(http.StatusUnauthorized, `{}`), nil
+		}
+		return stubResponse(http.StatusOK, `{
+			"data": {
+				"u0": {
+					"login": "alice", "name": "Alice", "location": "Berlin", "url": "https://github.com/alice",
+					"organizations": {"nodes": [{"login": "acme"}]},
+					"pinnedItems": {"nodes": [{"nameWithOwner": "alice/pinned"}]},
+					"repositories": {"nodes": [{"nameWithOwner": "alice/tool", "description": "acme tooling"}]},
+					"socialAccounts": {"nodes": [{"provider": "LINKEDIN", "url": "https://www.linkedin.com/in/alice"}]}
+				},
+				"u1": null
+			},
+			"errors": [{"type": "NOT_FOUND", "message": "Could not resolve to a User with the login of 'acme'."}]
+		}`), nil
+	}))
+
+	candidates, err := fetchGraphQLCandidates(context.Background(), "token", []string{"alice", "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 1 {
+		t.Fatalf("got %d candidates, want 1", len(candidates))
+	}
+	alice := candidates[0]
+	if alice.User.Login != "alice" || alice.User.Location != "Berlin" || alice.User.HTMLURL != "https://github.com/alice" {
+		t.Errorf("user = %+v", alice.User)
+	}
+	if !strings.Contains(alice.repos(context.Background(), "token"), "acme tooling") || !strings.Contains(alice.Repos, "alice/pinned") {
+		t.Errorf("repos = %s", alice.Repos)
+	}
+	if !reflect.DeepEqual(alice.Social, []string{"https://www.linkedin.com/in/alice"}) {
+		t.Errorf("social = %v", alice.Social)
+	}
+	for org, want := range map[string]bool{"ACME": true, "other": false} {
+		if member, err := alice.isOrgMember(context.Background(), "token", org); err != nil || member != want {
+			t.Errorf("isOrgMember(%s) = %v, %v, want %v", org, member, err, want)
+		}
+	}
+
+	if _, err := fetchGraphQLCandidates(context.Background(), "bad", []string{"alice", "acme"}); err == nil {
+		t.Error("fetchGraphQLCandidates() ignored HTTP 401")
+	}
+}
+
+func TestCandidateReposREST(t *testing.T) {
+	withHTTPClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/users/alice/repos":
+			return stubResponse(http.StatusOK, `[{"full_name":"alice/acme-tools"}]`), nil
+		case "/users/gone/repos":
+			return stubResponse(http.StatusNotFound, `{"message":"Not Found"}`), nil
+		}
+		return nil, errors.New("connection refused")
+	}))
+
+	tests := []struct {
+		login   string
+		want    string
+		fetched bool
+	}{
+		{login: "alice", want: `[{"full_name":"alice/acme-tools"}]`, fetched: true},
+		{login: "gone", want: ""},
+		{login: "offline", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.login, func(t *testing.T) {
+			candidate := &githubCandidate{User: githubUserInfo{Login: tt.login}}
+			if got := candidate.repos(context.Background(), "token"); got != tt.want {
+				t.Errorf("repos() = %q, want %q", got, tt.want)
+			}
+			if candidate.ReposFetched != tt.fetched {
+				t.Errorf("ReposFetched = %v, want %v", candidate.ReposFetched, tt.fetched)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+type htmlEvidenceCount struct {
+	Kind  string
+	Count int
+}
+
+type htmlReportData struct {
+	Title       string
+	GeneratedAt time.Time
+	Results     Results
+	Employees   int
+	Matched     int
+	Accounts    int
+	Ambiguous   int
+	Rejected    int
+	Evidence    []htmlEvidenceCount
+	People      []htmlPerson
+}
+
+type htmlPerson struct {
+	Employee Employee
+	Matches  []htmlMatch
+}
+
+type htmlMatch struct {
+	Match
+	Avatar template.URL
+}
+
+// EvidenceKinds lists the kinds of evidence of the match once each, for the
+// table.
+func (m htmlMatch) EvidenceKinds() string {
+	var kinds []string
+	seen := make(map[string]bool)
+	for _, evidence := range m.Evidence {
+		if !seen[evidence.Kind] {
+			seen[evidence.Kind] = true
+			kinds = append(kinds, evidence.Kind)
+		}
+	}
+	return strings.Join(kinds, ", ")
+}
+
+// writeHTMLReport writes results as a single HTML file that needs nothing but
+// a browser. Rejected matches are left out. With embedAvatars the avatars are
+// downloaded into the file, otherwise they are linked from GitHub.
+func writeHTMLReport(filename string, results Results, embedAvatars bool) error {
+	grouped := matchesByEmployee(results.Matches)
+	data := htmlReportData{
+		Title:       "mulef report",
+		GeneratedAt: time.Now(),
+		Results:     results,
+		Employees:   len(results.Employees),
+	}
+
+	kinds := make(map[string]int)
+	for _, employee := range matchedEmployees(results, grouped) {
+		person := htmlPerson{Employee: employee}
+		for _, match := range grouped[employeeKey(employee)] {
+			if match.Decision == decisionRejected {
+				data.Rejected++
+				continue
+			}
+			if match.Ambiguous {
+				data.Ambiguous++
+			}
+			for _, evidence := range match.Evidence {
+				kinds[evidence.Kind]++
+			}
+			// template.URL lets data URLs through, so only trust https links.
+			var avatar template.URL
+			if strings.HasPrefix(match.AvatarURL, "https://") {
+				avatar = template.URL(match.AvatarURL)
+			}
+			if embedAvatars && avatar != "" {
+				if embedded, err := embedAvatar(match.AvatarURL); err == nil {
+					avatar = embedded
+				} else {
+					color.Yellow("[!] Can not embed the avatar of %s: %v", match.Login, err)
+				}
+			}
+			person.Matches = append(person.Matches, htmlMatch{Match: match, Avatar: avatar})
+		}
+		if len(person.Matches) == 0 {
+			continue
+		}
+		sort.SliceStable(person.Matches, func(i, j int) bool {
+			return person.Matches[i].Score > person.Matches[j].Score
+		})
+		data.Matched++
+		data.Accounts += len(person.Matches)
+		data.People = append(data.People, person)
+	}
+	for kind, count := range kinds {
+		data.Evidence = append(data.Evidence, htmlEvidenceCount{Kind: kind, Count: count})
+	}
+	sort.Slice(data.Evidence, func(i, j int) bool {
+		if data.Evidence[i].Count != data.Evidence[j].Count {
+			return data.Evidence[i].Count > data.Evidence[j].Count
+		}
+		return data.Evidence[i].Kind < data.Evidence[j].Kind
+	})
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := htmlReportTemplate.Execute(file, data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// embedAvatar downloads a small version of an avatar and returns it as a
+// data URL.
+func embedAvatar(avatarURL string) (template.URL, error) {
+	separator := "?"
+	if strings.Contains(avatarURL, "?") {
+		separator = "&"
+	}
+	resp, err := httpClient.Get(avatarURL + separator + "s=96")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(body)
+	}
+	return template.URL("data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(body)), nil
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em auto; max-width: 1100px; color: #1f2328; padding: 0 1em; }
+h1 { margin-bottom: 0.2em; }
+.meta { color: #59636e; margin-bottom: 1.5em; }
+.stats { display: flex; flex-wrap: wrap; gap: 1em; margin-bottom: 2em; }
+.stat { border: 1px solid #d1d9e0; border-radius: 6px; padding: 0.8em 1.2em; min-width: 120px; }
+.stat b { display: block; font-size: 1.8em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { text-align: left; padding: 0.45em 0.6em; border-bottom: 1px solid #d1d9e0; vertical-align: top; }
+th { cursor: pointer; user-select: none; background: #f6f8fa; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+.card { border: 1px solid #d1d9e0; border-radius: 6px; padding: 1em; margin-bottom: 1em; }
+.card h3 { margin: 0 0 0.2em 0; }
+.match { display: flex; gap: 1em; margin-top: 1em; }
+.match img { width: 64px; height: 64px; border-radius: 50%; }
+.score { font-weight: bold; }
+.ambiguous { color: #9a6700; }
+.decision { color: #1a7f37; }
+ul { margin: 0.3em 0; padding-left: 1.2em; }
+code { background: #f6f8fa; padding: 0 0.3em; border-radius: 3px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">
+Run started {{.Results.StartedAt.Format "2006-01-02 15:04"}}, mode {{.Results.Mode}}{{if .Results.Keywords}}, keywords {{join .Results.Keywords ", "}}{{end}}. Generated {{.GeneratedAt.Format "2006-01-02 15:04"}}.
+</div>
+
+<div class="stats">
+<div class="stat"><b>{{.Employees}}</b>employees</div>
+<div class="stat"><b>{{.Matched}}</b>with GitHub accounts</div>
+<div class="stat"><b>{{.Accounts}}</b>accounts</div>
+<div class="stat"><b>{{.Ambiguous}}</b>ambiguous</div>
+{{if .Rejected}}<div class="stat"><b>{{.Rejected}}</b>rejected, not listed</div>{{end}}
+{{range .Evidence}}<div class="stat"><b>{{.Count}}</b>{{.Kind}}</div>
+{{end}}</div>
+
+<h2>Accounts</h2>
+<table id="accounts">
+<thead><tr><th>Employee</th><th>Title</th><th>Location</th><th>Status</th><th>GitHub</th><th data-type="number">Score</th><th>Evidence</th><th>Decision</th></tr></thead>
+<tbody>
+{{range .People}}{{$employee := .Employee}}{{range .Matches}}<tr>
+<td><a href="#{{.Login}}">{{$employee.Name}}</a></td>
+<td>{{$employee.Title}}</td>
+<td>{{$employee.Location}}</td>
+<td>{{$employee.Status}}</td>
+<td><a href="{{.ProfileURL}}">{{.Login}}</a></td>
+<td>{{.Score}}</td>
+<td>{{.EvidenceKinds}}</td>
+<td>{{.Decision}}{{if .Ambiguous}} <span class="ambiguous">ambiguous</span>{{end}}</td>
+</tr>
+{{end}}{{end}}</tbody>
+</table>
+
+<h2>Evidence</h2>
+{{range .People}}<div class="card">
+<h3>{{.Employee.Name}}</h3>
+<div class="meta">{{with .Employee.Title}}{{.}} · {{end}}{{with .Employee.Location}}{{.}} · {{end}}{{with .Employee.Status}}{{.}} employee · {{end}}{{with .Employee.ProfileURL}}<a href="{{.}}">LinkedIn</a>{{end}}</div>
+{{range .Matches}}<div class="match" id="{{.Login}}">
+{{if .Avatar}}<img src="{{.Avatar}}" alt="">{{end}}
+<div>
+<a href="{{.ProfileURL}}"><b>{{.Login}}</b></a>{{with .Name}} {{.}}{{end}}{{with .Location}} · {{.}}{{end}}
+<div><span class="score">score {{.Score}}</span>{{with .Decision}} · <span class="decision">{{.}}</span>{{end}}{{if .Ambiguous}} · <span class="ambiguous">ambiguous, also found for {{join .Contenders ", "}}</span>{{end}}</div>
+<ul>
+{{range .Evidence}}<li><code>{{.Kind}}</code> {{.Detail}}{{if .URL}} <a href="{{.URL}}">{{.URL}}</a>{{end}}</li>
+{{end}}</ul>
+</div>
+</div>
+{{end}}</div>
+{{end}}
+<script>
+document.querySelectorAll("#accounts th").forEach(function (th, column) {
+  th.addEventListener("click", function () {
+    var tbody = document.querySelector("#accounts tbody");
+    var ascending = !th.classList.contains("asc");
+    var numeric = th.dataset.type === "number";
+    document.querySelectorAll("#accounts th").forEach(function (other) { other.classList.remove("asc", "desc"); });
+    th.classList.add(ascending ? "asc" : "desc");
+    var rows = Array.prototype.slice.call(tbody.rows);
+    rows.sort(function (a, b) {
+      var x = a.cells[column].textContent.trim(), y = b.cells[column].textContent.trim();
+      var order = numeric ? parseFloat(x) - parseFloat(y) : x.localeCompare(y);
+      return ascending ? order : -order;
+    });
+    rows.forEach(function (row) { tbody.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`))
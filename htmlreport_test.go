@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteHTMLReport(t *testing.T) {
+	withHTTPClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp := stubResponse(http.StatusOK, "\x89PNG\r\n\x1a\n")
+		resp.Header.Set("Content-Type", "image/png")
+		return resp, nil
+	}))
+
+	alice := Employee{Name: "Alice <img src=x>", Location: "Berlin"}
+	bob := Employee{Name: "Bob", Location: "Paris"}
+	results := Results{
+		Mode:      modeLocation,
+		Employees: []Employee{alice, bob},
+		Matches: []Match{
+			{Employee: alice, Login: "alice", Score: 2, AvatarURL: "https://avatars.githubusercontent.com/u/1", Evidence: []Evidence{{Kind: evidenceLocation, Detail: "Berlin"}}},
+			{Employee: alice, Login: "alice-evil", Score: 5, AvatarURL: "javascript:alert(1)", Ambiguous: true, Contenders: []string{"Bob"}},
+			{Employee: bob, Login: "bob-rejected", Decision: decisionRejected},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		embed    bool
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "linked avatars",
+			contains: []string{"Alice &lt;img src=x&gt;", "https://avatars.githubusercontent.com/u/1", "alice-evil"},
+			excludes: []string{"<img src=x>", "bob-rejected", "javascript:alert", "data:image/png"},
+		},
+		{
+			name:     "embedded avatars",
+			embed:    true,
+			contains: []string{"data:image/png;base64,"},
+			excludes: []string{"https://avatars.githubusercontent.com/u/1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "report.html")
+			if err := writeHTMLReport(filename, results, tt.embed); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			html := string(data)
+			for _, want := range tt.contains {
+				if !strings.Contains(html, want) {
+					t.Errorf("report does not contain %q", want)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(html, unwanted) {
+					t.Errorf("report contains %q", unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestHTMLMatchEvidenceKinds(t *testing.T) {
+	match := htmlMatch{Match: Match{Evidence: []Evidence{
+		{Kind: evidenceLocation}, {Kind: evidenceKeywordRepos}, {Kind: evidenceLocation},
+	}}}
+	if got, want := match.EvidenceKinds(), "location, keyword-repos"; got != want {
+		t.Errorf("EvidenceKinds() = %q, want %q", got, want)
+	}
+}
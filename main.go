@@ -1,17 +1,15 @@
 package main
 
 import (
-	"crypto/tls"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"math"
 	"net/http"
 	"net/url"
 	"os"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
@@ -240,96 +238,194 @@ type githubResponseOfSearchingForUsers struct {
 	} `json:"items"`
 }
 type Employee struct {
-	Name     string `json:"name"`
-	Location string `json:"location"`
+	URN        string `json:"urn,omitempty"`
+	Name       string `json:"name"`
+	Location   string `json:"location"`
+	Title      string `json:"title,omitempty"`
+	Email      string `json:"email,omitempty"`
+	Status     string `json:"status,omitempty"`
+	ProfileURL string `json:"profile_url,omitempty"`
 }
 
-func getResponseFromFile(filename string, optionalArg ...string) []byte {
-	rawReq, err := ioutil.ReadFile(filename)
+func getLinkedInResponse(captured capturedRequest, start int, slice searchSlice) ([]byte, error) {
+	req, err := captured.newRequest(start, slice)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	proxyURL, err := url.Parse("http://127.0.0.1:8080")
-	if err != nil {
-		fmt.Println("Error parsing proxy URL:", err)
-		// return
+	// Redirects are how LinkedIn sends expired sessions to the login page, so
+	// they are reported instead of followed.
+	client := *httpClient
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
 	}
 
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		Proxy:           http.ProxyURL(proxyURL),
+	// Send the HTTP request and print the response
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	// Create a new client with the transport
-	client := &http.Client{
-		Transport: transport,
-	}
-	regex := regexp.MustCompile("start:[^,]+")
-	// Parse the raw HTTP request
-	reqParts := strings.SplitN(string(rawReq), "\r\n\r\n", 2)
-	reqLine := strings.Split(strings.TrimSpace(reqParts[0]), " ")
-	method := reqLine[0]
-	url := reqLine[1]
-	url = "https://www.linkedin.com" + url
-	if len(optionalArg) > 0 {
-		// url = strings.Replace(url, "username", optionalArg[0], 1)
-		url = regex.ReplaceAllString(url, "start:"+optionalArg[0])
-	} else {
-		url = regex.ReplaceAllString(url, "start:0")
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
 	}
-	body := ""
-	if len(reqParts) > 1 {
-		body = reqParts[1]
+	if err := checkLinkedInResponse(resp, body); err != nil {
+		return nil, err
 	}
+	return body, nil
+}
+
+// linkedInResultCap is the number of results the voyager search endpoint
+// will page through, no matter how large totalResultCount is.
+const linkedInResultCap = 1000
+
+// linkedInDefaultPageSize is used when a response does not report paging.count.
+const linkedInDefaultPageSize = 10
 
-	// Create a new HTTP request
-	req, err := http.NewRequest(method, url, strings.NewReader(body))
+type linkedInPage struct {
+	Employees []Employee
+	Results   int
+	Start     int
+	Count     int
+	Total     int
+}
+
+func fetchLinkedInPage(captured capturedRequest, start int, slice searchSlice) (linkedInPage, error) {
+	if start == 0 && slice.empty() && captured.firstPage != nil {
+		return *captured.firstPage, nil
+	}
+	body, err := getLinkedInResponse(captured, start, slice)
 	if err != nil {
-		panic(err)
+		linkedInPagesMetric.add(1, "error")
+		return linkedInPage{}, err
 	}
+	linkedInPagesMetric.add(1, "ok")
+	return parseLinkedInPage(body)
+}
 
-	// Parse the headers from the raw HTTP request and add them to the new request
-	headers := strings.Split(reqParts[0], "\n")[1:]
-	for _, header := range headers {
-		headerParts := strings.SplitN(header, ":", 2)
-		req.Header.Add(strings.TrimSpace(headerParts[0]), strings.TrimSpace(headerParts[1]))
+// parseLinkedInPage reads the employees and the paging of a search page.
+func parseLinkedInPage(body []byte) (linkedInPage, error) {
+	var reqBody2 response
+	if err := json.Unmarshal(body, &reqBody2); err != nil { // Parse []byte to the go struct pointer
+		return linkedInPage{}, fmt.Errorf("can not unmarshal JSON: %v: %s", err, snippet(body))
 	}
 
-	// Send the HTTP request and print the response
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Println("Error sending request:", err)
-		// return
+	clusters := reqBody2.Data.Data.SearchDashClustersByAll
+	page := linkedInPage{
+		Start: clusters.Paging.Start,
+		Count: clusters.Paging.Count,
+		Total: clusters.Paging.Total,
+	}
+	if page.Total == 0 {
+		page.Total = clusters.Metadata.TotalResultCount
 	}
-	defer resp.Body.Close()
 
-	respBody, err := ioutil.ReadAll(resp.Body)
+	for _, includedRec := range reqBody2.Included {
+		if includedRec.Title.Text == "" {
+			continue
+		}
+		page.Results++
+		if includedRec.Title.Text != "null" && !strings.Contains(includedRec.Title.Text, "Member") && !strings.Contains(includedRec.Title.Text, "Founder") && !strings.Contains(includedRec.Title.Text, "CEO") && !strings.Contains(includedRec.Title.Text, "CTO") && !strings.Contains(includedRec.Title.Text, "COO") && !strings.Contains(includedRec.Title.Text, "CFO") && !strings.Contains(includedRec.Title.Text, "CIO") && !strings.Contains(includedRec.Title.Text, "CPO") && !strings.Contains(includedRec.Title.Text, "CMO") && !strings.Contains(includedRec.Title.Text, "CDO") && !strings.Contains(includedRec.Title.Text, "CRO") && !strings.Contains(includedRec.Title.Text, "CSO") && !strings.Contains(includedRec.Title.Text, "CLO") && !strings.Contains(includedRec.Title.Text, "might benefit") {
+
+			employeeName := includedRec.Title.Text
+			employeeLocation := includedRec.SecondarySubtitle.Text
+			employeeTitle := includedRec.PrimarySubtitle.Text
+
+			employeeProfileURL := includedRec.NavigationURL
+			if i := strings.Index(employeeProfileURL, "?"); i >= 0 {
+				employeeProfileURL = employeeProfileURL[:i]
+			}
+			employeeURN := includedRec.TrackingUrn
+			if employeeURN == "" {
+				employeeURN = includedRec.EntityUrn
+			}
+
+			page.Employees = append(page.Employees, Employee{URN: employeeURN, Name: employeeName, Location: employeeLocation, Title: employeeTitle, ProfileURL: employeeProfileURL})
+		}
+
+	}
+	return page, nil
+}
+
+// fetchLinkedInEmployees walks every page of the search captured in
+// captured, narrowed down by slice. It follows the paging metadata LinkedIn
+// returns, stops on the first empty page and never asks for more than
+// linkedInResultCap results.
+func fetchLinkedInEmployees(captured capturedRequest, slice searchSlice) []Employee {
+	first, err := fetchLinkedInPage(captured, 0, slice)
 	if err != nil {
-		panic(err)
+		color.Red("[-] Can not fetch LinkedIn page at start 0: %v", err)
+		return nil
 	}
+	if first.Total > linkedInResultCap {
+		color.Yellow("[!] LinkedIn reports %d results%s but only returns the first %d, the rest will be missed", first.Total, slice.describe(), linkedInResultCap)
+	}
+	return collectLinkedInPages(captured, slice, first)
+}
 
-	return respBody
+// linkedInResultLimit is the number of results to page through for a search
+// reporting total. Some responses leave the total out, those are paged
+// through until a page comes back empty.
+func linkedInResultLimit(total int) int {
+	if total <= 0 || total > linkedInResultCap {
+		return linkedInResultCap
+	}
+	return total
 }
-func sendHTTPRequest(start string) []Employee {
-	var employees []Employee
 
-	var reqBody2 response
-	if err := json.Unmarshal(getResponseFromFile("testdir/request.txt", start), &reqBody2); err != nil { // Parse []byte to the go struct pointer
-		fmt.Println("Can not unmarshal JSON")
+// linkedInPageCount is the number of pages LinkedIn will return for the
+// search whose first page is first, at most when it does not report a total.
+func linkedInPageCount(first linkedInPage) int {
+	count := first.Count
+	if count <= 0 {
+		count = linkedInDefaultPageSize
+	}
+	if pages := (linkedInResultLimit(first.Total) + count - 1) / count; pages > 1 {
+		return pages
 	}
+	return 1
+}
 
-	for _, includedRec := range reqBody2.Included {
-		if includedRec.Title.Text != "null" && includedRec.Title.Text != "" && !strings.Contains(includedRec.Title.Text, "Member") && !strings.Contains(includedRec.Title.Text, "Founder") && !strings.Contains(includedRec.Title.Text, "CEO") && !strings.Contains(includedRec.Title.Text, "CTO") && !strings.Contains(includedRec.Title.Text, "COO") && !strings.Contains(includedRec.Title.Text, "CFO") && !strings.Contains(includedRec.Title.Text, "CIO") && !strings.Contains(includedRec.Title.Text, "CPO") && !strings.Contains(includedRec.Title.Text, "CMO") && !strings.Contains(includedRec.Title.Text, "CDO") && !strings.Contains(includedRec.Title.Text, "CRO") && !strings.Contains(includedRec.Title.Text, "CSO") && !strings.Contains(includedRec.Title.Text, "CLO") && !strings.Contains(includedRec.Title.Text, "might benefit") {
+// collectLinkedInPages gathers the employees of first and every page that
+// follows it.
+func collectLinkedInPages(captured capturedRequest, slice searchSlice, first linkedInPage) []Employee {
+	var employees []Employee
 
-			employeeName := includedRec.Title.Text
-			employeeLocation := includedRec.SecondarySubtitle.Text
+	// The pages planned from the first one are added to the progress, and
+	// those not fetched after all taken away again.
+	planned := linkedInPageCount(first)
+	fetched := 1
+	captured.progress.add(1, planned)
+	defer func() {
+		captured.progress.add(0, fetched-planned)
+	}()
+
+	page := first
+	start := 0
+	for page.Results > 0 {
+		employees = append(employees, page.Employees...)
+
+		count := page.Count
+		if count <= 0 {
+			count = linkedInDefaultPageSize
+		}
+		start += count
 
-			employees = append(employees, Employee{Name: employeeName, Location: employeeLocation})
+		if start >= linkedInResultLimit(page.Total) {
+			break
 		}
 
+		var err error
+		page, err = fetchLinkedInPage(captured, start, slice)
+		if err != nil {
+			color.Red("[-] Can not fetch LinkedIn page at start %d: %v", start, err)
+			break
+		}
+		fetched++
+		captured.progress.add(1, 0)
 	}
-	// return employeeJSON
 	return employees
 }
 
@@ -371,10 +467,10 @@ type githubUserInfo struct {
 	UpdatedAt         time.Time `json:"updated_at"`
 }
 
-func getGithubUser(token string, username string) (githubUserInfo, error) {
+func getGithubUser(ctx context.Context, token string, username string) (githubUserInfo, error) {
 	url := "https://api.github.com/users/" + username
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return githubUserInfo{}, err
 	}
@@ -382,8 +478,7 @@ func getGithubUser(token string, username string) (githubUserInfo, error) {
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return githubUserInfo{}, err
 	}
@@ -394,14 +489,14 @@ func getGithubUser(token string, username string) (githubUserInfo, error) {
 	if err != nil {
 		return githubUserInfo{}, err
 	}
-	time.Sleep(500 * time.Millisecond)
+	githubPause(ctx, resp)
 	return responseBody, nil
 }
 
-func searchUsers(token string, username string) (githubResponseOfSearchingForUsers, error) {
+func searchUsers(ctx context.Context, token string, username string) (githubResponseOfSearchingForUsers, error) {
 	url := "https://api.github.com/search/users?q=" + username
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return githubResponseOfSearchingForUsers{}, err
 	}
@@ -409,8 +504,7 @@ func searchUsers(token string, username string) (githubResponseOfSearchingForUse
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return githubResponseOfSearchingForUsers{}, err
 	}
@@ -421,10 +515,31 @@ func searchUsers(token string, username string) (githubResponseOfSearchingForUse
 	if err != nil {
 		return githubResponseOfSearchingForUsers{}, err
 	}
-	time.Sleep(500 * time.Millisecond)
+	githubPause(ctx, resp)
 	return responseBody, nil
 
 }
+func isPublicOrgMember(ctx context.Context, token string, org string, username string) (bool, error) {
+	url := "https://api.github.com/orgs/" + org + "/public_members/" + username
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	githubPause(ctx, resp)
+	return resp.StatusCode == http.StatusNoContent, nil
+}
+
 func generateLocationVariations(location string) []string {
 	var rev_slc []string
 	// split the location string into separate words
@@ -471,38 +586,103 @@ func isInSlice(s string, slice []string) bool {
 	return false
 }
 
-func getUserReposDetails(username string, token string) string {
-
+// getUserReposDetails returns the repositories of username as the JSON GitHub
+// answers with.
+func getUserReposDetails(ctx context.Context, username string, token string) (string, error) {
 	url := "https://api.github.com/users/" + username + "/repos"
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		fmt.Println("error")
+		return "", err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Accept", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		fmt.Println("error")
+		return "", err
 	}
 	defer resp.Body.Close()
 
 	responseBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Println("error")
+		return "", err
+	}
+	githubPause(ctx, resp)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GitHub answered %s with HTTP %d: %s", url, resp.StatusCode, snippet(responseBody))
 	}
+	return string(responseBody), nil
+}
 
-	time.Sleep(500 * time.Millisecond)
-	return string(responseBody)
+// githubCommitEmail is the author email of a commit a user pushed.
+type githubCommitEmail struct {
+	Email string
+	Repo  string
+	SHA   string
+}
+
+// getUserCommitEmails returns the author emails of the commits in the recent
+// public push events of the user.
+func getUserCommitEmails(ctx context.Context, username string, token string) ([]githubCommitEmail, error) {
+	url := "https://api.github.com/users/" + username + "/events/public?per_page=100"
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	githubPause(ctx, resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub answered %s with HTTP %d: %s", url, resp.StatusCode, snippet(responseBody))
+	}
+
+	var events []struct {
+		Type string `json:"type"`
+		Repo struct {
+			Name string `json:"name"`
+		} `json:"repo"`
+		Payload struct {
+			Commits []struct {
+				SHA    string `json:"sha"`
+				Author struct {
+					Email string `json:"email"`
+				} `json:"author"`
+			} `json:"commits"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(responseBody, &events); err != nil {
+		return nil, err
+	}
+	var emails []githubCommitEmail
+	for _, event := range events {
+		if event.Type != "PushEvent" {
+			continue
+		}
+		for _, commit := range event.Payload.Commits {
+			emails = append(emails, githubCommitEmail{Email: commit.Author.Email, Repo: event.Repo.Name, SHA: commit.SHA})
+		}
+	}
+	return emails, nil
 }
 
-func getURLResponse(url string, authToken string) (string, error) {
+func getURLResponse(ctx context.Context, url string, authToken string) (string, error) {
 	// Create new HTTP GET request
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -510,9 +690,8 @@ func getURLResponse(url string, authToken string) (string, error) {
 	// Add authorization header to request
 	req.Header.Add("Authorization", "Bearer "+authToken)
 
-	// Send HTTP request with client
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// Send HTTP request with the shared client
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -526,7 +705,7 @@ func getURLResponse(url string, authToken string) (string, error) {
 
 	// Convert response body to string
 	response := string(body)
-	time.Sleep(500 * time.Millisecond)
+	githubPause(ctx, resp)
 	return response, nil
 }
 
@@ -621,204 +800,241 @@ func appendToFile(filename string, text string) error {
 	return err
 }
 
-func threadingInLinkedInEmployees(employee Employee, eligbleUsers []string, locationMethod bool, userKeywords []string, keywordsMethod bool, outputToFile bool, githubToken string, optionalArgs ...string) {
-	var outputLocation string
-
-	if len(optionalArgs) > 0 && outputToFile {
-		outputLocation = optionalArgs[0]
+// matchEmployee searches GitHub for accounts that may belong to employee and
+// returns those with evidence for the given mode.
+func matchEmployee(employee Employee, options matchOptions) []Match {
+	if employee.Name == "" {
+		return nil
 	}
-	foundKeyword := false
-	encodedUserName := url.QueryEscape(employee.Name)
-	if employee.Name != "" || len(employee.Name) > 0 {
+	if employee.Status == employmentPast {
+		color.Cyan("[+] Searching For: " + employee.Name + " (past employee)")
+	} else {
 		color.Cyan("[+] Searching For: " + employee.Name)
 	}
-	foundUsers, err := searchUsers(githubToken, encodedUserName)
-	locationsGeneratedFromLinkedIn := generateLocationVariations(employee.Location)
+
+	foundUsers, err := searchUsers(options.runContext(), options.Token, url.QueryEscape(employee.Name))
 	if err != nil {
 		color.Red("[-] Can not search users")
 	}
-	for i := 0; i < len(foundUsers.Items); i++ {
-		// fmt.Println("  [+] Testing user: ", foundUsers.Items[i].Login)
-		user := foundUsers.Items[i]
-		userInformaiton, err := getGithubUser(githubToken, user.Login)
-
-		if err != nil {
-			color.Red("[-] Can not get user information")
+	if employee.Email != "" {
+		// A public email on the profile is the strongest lead, so try it first.
+		foundByEmail, err := searchUsers(options.runContext(), options.Token, url.QueryEscape(employee.Email+" in:email"))
+		if err == nil {
+			seen := make(map[string]bool)
+			for _, user := range foundByEmail.Items {
+				seen[user.Login] = true
+			}
+			for _, user := range foundUsers.Items {
+				if !seen[user.Login] {
+					foundByEmail.Items = append(foundByEmail.Items, user)
+				}
+			}
+			foundUsers = foundByEmail
+		}
+	}
+	var locationsGeneratedFromLinkedIn []string
+	for _, location := range append(generateLocationVariations(employee.Location), options.Locations...) {
+		if location != "" {
+			locationsGeneratedFromLinkedIn = append(locationsGeneratedFromLinkedIn, location)
 		}
-		if locationMethod {
+	}
+
+	var logins []string
+	for _, user := range foundUsers.Items {
+		logins = append(logins, user.Login)
+	}
+
+	var matches []Match
+	for _, candidate := range fetchCandidates(logins, options) {
+		userInformaiton := candidate.User
+
+		var evidence []Evidence
+		if options.Mode == modeLocation {
 			userLocation := userInformaiton.Location
 
 			if isInSlice(userLocation, locationsGeneratedFromLinkedIn) {
-				color.Green("[*] Found: " + user.Login)
-				if outputToFile {
-					appendToFile(outputLocation, user.Login+"\n")
-				}
-			}
-		} else if keywordsMethod {
-			userReposInfo := getUserReposDetails(user.Login, githubToken)
-
-			for _, keyword := range userKeywords {
-				if foundKeyword {
-					break
-				} else if strings.Contains(keyword, userReposInfo) {
-					color.Green("[*] Found: " + user.Login + ", keyword: " + keyword)
-					// eligbleUsers = append(eligbleUsers, user.Login)
-					if outputToFile {
-						appendToFile(outputLocation, user.Login+"\n")
-					}
-					foundKeyword = true
-					break
-				} else {
-					stringOfUserInfo, err := json.Marshal(userInformaiton)
-					if err != nil {
-						fmt.Println(stringOfUserInfo)
-						color.Red("[-] Can not get user repos information #0")
-					}
-
-					if strings.Contains(string(stringOfUserInfo), keyword) {
-						color.Green("[*] Found: " + user.Login + ", keyword: " + keyword)
-						if outputToFile {
-							appendToFile(outputLocation, user.Login+"\n")
-						}
-						foundKeyword = true
-						break
-					}
-					searchResults, err := getURLResponse("https://api.github.com/search/code?q=user:"+user.Login+"+"+keyword, githubToken)
-					if err != nil {
-						color.Red("[-] https://api.github.com/search/code?q=user:" + user.Login + "+" + keyword)
-						color.Red("[-] Can not get user repos information #1")
-					}
-
-					var githubsearchresultsobject githubsearchresults
-					err = json.Unmarshal([]byte(searchResults), &githubsearchresultsobject)
-					if err != nil {
-						{
-						}
-					}
-					if githubsearchresultsobject.TotalCount > 0 {
-						color.Green("[*] Found: " + user.Login + ", keyword: " + keyword)
-						foundKeyword = true
-						if outputToFile {
-							appendToFile(outputLocation, user.Login+"\n")
-						}
-						break
-					}
-
-				}
+				evidence = append(evidence, Evidence{Kind: evidenceLocation, Detail: userLocation + " matches " + employee.Location, URL: userInformaiton.HTMLURL})
 			}
+		} else if found, ok := findKeyword(candidate, options); ok {
+			evidence = append(evidence, found)
+		}
+		evidence = append(evidence, profileEvidence(candidate, options)...)
+
+		if len(evidence) > 0 {
+			color.Green(foundMessage(userInformaiton.Login, evidence))
+			matches = append(matches, Match{
+				Employee:   employee,
+				Login:      userInformaiton.Login,
+				ProfileURL: userInformaiton.HTMLURL,
+				Name:       userInformaiton.Name,
+				Location:   userInformaiton.Location,
+				AvatarURL:  userInformaiton.AvatarURL,
+				Score:      options.score(evidence),
+				Evidence:   evidence,
+			})
 		}
-
 	}
+	return matches
 }
-func main() {
 
-	color.Green("\n\t\t                                    /$$$$$$           ")
-	color.Green("\t\t                                   /$$$_  $$          ")
-	color.Green("\t\t /$$$$$$/$$$$  /$$   /$$ /$$   /$$| $$$$\\ $$ /$$   /$$")
-	color.Green("\t\t| $$_  $$_  $$| $$  | $$|  $$ /$$/| $$ $$ $$|  $$ /$$/")
-	color.Green("\t\t| $$ \\ $$ \\ $$| $$  | $$ \\  $$$$/ | $$\\ $$$$ \\  $$$$/ ")
-	color.Green("\t\t| $$ | $$ | $$| $$  | $$  >$$  $$ | $$ \\ $$$  >$$  $$ ")
-	color.Green("\t\t| $$ | $$ | $$|  $$$$$$/ /$$/\\  $$|  $$$$$$/ /$$/\\  $$")
-	color.Green("\t\t|__/ |__/ |__/ \\______/ |__/  \\__/ \\______/ |__/  \\__/")
-	fmt.Println()
-	color.Green("\t\t[+] mulef - LinkedIn Employee Finder - v1.0")
-	color.Green("\t\t[+] github@mux0x")
-	fmt.Println()
-	keywords := flag.String("keywords", "", "comma-separated list of keywords")
-	mode := flag.String("mode", "", "mode of finding employees (location, keywords)")
-	requestFile := flag.String("LinkedInRequest", "", "path of the linkedin request file")
-	githubToken := flag.String("token", "", "github token")
-	outputLocation := flag.String("output", "", "path of the output file")
-	var outputToFile bool
-	flag.Parse()
-
-	if flag.Lookup("keywords") == nil {
-		color.Red("[-] Keywords flag not specified")
-		flag.Usage()
-		os.Exit(1)
+// foundMessage is the line printed for a match, naming the keyword or other
+// evidence it was found with.
+func foundMessage(login string, evidence []Evidence) string {
+	message := "[*] Found: " + login
+	for _, e := range evidence {
+		if e.Keyword != "" {
+			message += ", keyword: " + e.Keyword
+		} else if e.Kind != evidenceLocation {
+			message += ", " + e.Detail
+		}
 	}
-	if flag.Lookup("mode") == nil {
-		color.Red("[-] Mode flag not specified")
-		flag.Usage()
-		os.Exit(1)
+	return message
+}
+
+// corporateEmail reports whether email is on one of the company domains.
+func corporateEmail(email string, domains []string) bool {
+	if email == "" {
+		return false
 	}
-	if flag.Lookup("LinkedInRequest") == nil {
-		color.Red("[-] LinkedInRequest flag not specified")
-		flag.Usage()
-		os.Exit(1)
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "@"))
+		if strings.HasSuffix(strings.ToLower(email), "@"+domain) {
+			return true
+		}
 	}
-	if flag.Lookup("token") == nil {
-		color.Red("[-] Token flag not specified")
-		flag.Usage()
-		os.Exit(1)
+	return false
+}
+
+// profileEvidence checks the profile and the emails of recent commits against
+// the company domains, aliases and GitHub organizations configured for the
+// target.
+func profileEvidence(candidate *githubCandidate, options matchOptions) []Evidence {
+	userInformaiton := candidate.User
+	var evidence []Evidence
+
+	email, _ := userInformaiton.Email.(string)
+	if corporateEmail(email, options.Domains) {
+		evidence = append(evidence, Evidence{Kind: evidenceEmailDomain, Detail: "public email " + email, URL: userInformaiton.HTMLURL})
 	}
 
-	if flag.Lookup("output") != nil {
-		outputToFile = true
-	} else {
-		outputToFile = false
+	if len(options.Domains) > 0 {
+		for _, commit := range candidate.commitEmails(options.runContext(), options.Token) {
+			if corporateEmail(commit.Email, options.Domains) {
+				evidence = append(evidence, Evidence{Kind: evidenceCommitEmail, Detail: "commit email " + commit.Email + " in " + commit.Repo, URL: "https://github.com/" + commit.Repo + "/commit/" + commit.SHA})
+				break
+			}
+		}
 	}
 
-	allEmployees := Employees{}
-	var locationMethod bool
-	var keywordsMethod bool
+	company, _ := userInformaiton.Company.(string)
+	for _, alias := range options.Aliases {
+		if company != "" && strings.Contains(strings.ToLower(company), strings.ToLower(alias)) {
+			evidence = append(evidence, Evidence{Kind: evidenceCompanyField, Detail: "company " + company, URL: userInformaiton.HTMLURL})
+			break
+		}
+	}
 
-	if *mode == "location" {
-		locationMethod = true
-	} else if *mode == "keywords" {
-		keywordsMethod = true
-	} else {
-		color.Red("[-] Invalid mode")
-		os.Exit(1)
+	for _, org := range options.Orgs {
+		member, err := candidate.isOrgMember(options.runContext(), options.Token, org)
+		if err != nil {
+			color.Red("[-] Can not check membership of " + org)
+			continue
+		}
+		if member {
+			evidence = append(evidence, Evidence{Kind: evidenceOrgMember, Detail: "public member of " + org, URL: "https://github.com/orgs/" + org + "/people"})
+		}
 	}
+	return evidence
+}
 
-	var reqBody2 response
-	if err := json.Unmarshal(getResponseFromFile(*requestFile), &reqBody2); err != nil { // Parse []byte to the go struct pointer
-		color.Red("[-] Can not unmarshal JSON")
+// findKeyword looks for the first keyword in the user's repositories, their
+// profile and finally their code.
+func findKeyword(candidate *githubCandidate, options matchOptions) (Evidence, bool) {
+	userInformaiton := candidate.User
+	userReposInfo := candidate.repos(options.runContext(), options.Token)
+	stringOfUserInfo, err := json.Marshal(userInformaiton)
+	if err != nil {
+		color.Red("[-] Can not get user repos information #0")
 	}
+	stringOfUserInfo = append(stringOfUserInfo, strings.Join(candidate.Social, " ")...)
 
-	employeesCount := reqBody2.Data.Data.SearchDashClustersByAll.Metadata.TotalResultCount
+	for _, keyword := range options.Keywords {
+		if keyword == "" {
+			continue
+		}
+		if strings.Contains(userReposInfo, keyword) {
+			return Evidence{Kind: evidenceKeywordRepos, Keyword: keyword, Detail: "keyword in repositories", URL: userInformaiton.HTMLURL + "?tab=repositories"}, true
+		}
+		if strings.Contains(string(stringOfUserInfo), keyword) {
+			return Evidence{Kind: evidenceKeywordProfile, Keyword: keyword, Detail: "keyword in profile", URL: userInformaiton.HTMLURL}, true
+		}
 
-	numPages := int(math.Ceil(float64(employeesCount) / 10))
-	// fmt.Println("rounds: ", numPages)
-	color.Cyan("[+] Processing LinkedIn Request")
-	for i := 0; i <= numPages; i++ {
-		startIndex := i * 10
-		// fmt.Println("start: ", startIndex)
-		newEmployee := sendHTTPRequest(strconv.Itoa(startIndex))
-		allEmployees.Employees = append(allEmployees.Employees, newEmployee...)
+		codeSearchURL := "https://api.github.com/search/code?q=user:" + userInformaiton.Login + "+" + url.QueryEscape(keyword)
+		searchResults, err := getURLResponse(options.runContext(), codeSearchURL, options.Token)
+		if err != nil {
+			color.Red("[-] " + codeSearchURL)
+			color.Red("[-] Can not get user repos information #1")
+			continue
+		}
 
+		var githubsearchresultsobject githubsearchresults
+		if err := json.Unmarshal([]byte(searchResults), &githubsearchresultsobject); err != nil {
+			continue
+		}
+		if githubsearchresultsobject.TotalCount > 0 && len(githubsearchresultsobject.Items) > 0 {
+			item := githubsearchresultsobject.Items[0]
+			return Evidence{Kind: evidenceKeywordCode, Keyword: keyword, Detail: "keyword in " + item.Repository.FullName + "/" + item.Path, URL: item.HTMLURL}, true
+		}
 	}
-	userKeywords := strings.Split(*keywords, ",")
-	// fmt.Println("all employees: ", allEmployees.Employees)
-
-	// maxThreads := 1
-	threadCount := 1
+	return Evidence{}, false
+}
 
-	done := make(chan bool)
-	employeeChan := make(chan Employee)
+// linkedInSearch returns the company search to run, either the captured
+// request fromRequest or one built from the company name and the session
+// cookies.
+func linkedInSearch(fromRequest *capturedRequest, company string, cookies string, queryID string) (capturedRequest, error) {
+	if company == "" {
+		if fromRequest == nil {
+			return capturedRequest{}, errors.New("either -LinkedInRequest, -company or -employees is required")
+		}
+		return *fromRequest, nil
+	}
 
-	for i := 0; i < threadCount; i++ {
-		go func() {
-			for {
-				select {
-				case e := <-employeeChan:
-					threadingInLinkedInEmployees(e, userKeywords, locationMethod, userKeywords, keywordsMethod, outputToFile, *githubToken, *outputLocation)
-				case <-done:
-					return
-				}
-			}
-		}()
+	linkedInCookies, err := sessionCookies(cookies, fromRequest)
+	if err != nil {
+		return capturedRequest{}, err
+	}
+	if queryID == "" && fromRequest != nil {
+		queryID = capturedQueryID(*fromRequest)
 	}
 
-	for _, e := range allEmployees.Employees {
-		employeeChan <- e
+	color.Cyan("[+] Looking up LinkedIn company " + company)
+	captured, err := companySearchRequest(company, linkedInCookies, queryID)
+	if err != nil {
+		return capturedRequest{}, fmt.Errorf("can not build the LinkedIn search: %v", err)
 	}
+	return captured, nil
+}
 
-	close(employeeChan)
+func main() {
 
-	for i := 0; i < threadCount; i++ {
-		done <- true
-	}
+	color.Green("\n\t\t                                    /$$$$$$           ")
+	color.Green("\t\t                                   /$$$_  $$          ")
+	color.Green("\t\t /$$$$$$/$$$$  /$$   /$$ /$$   /$$| $$$$\\ $$ /$$   /$$")
+	color.Green("\t\t| $$_  $$_  $$| $$  | $$|  $$ /$$/| $$ $$ $$|  $$ /$$/")
+	color.Green("\t\t| $$ \\ $$ \\ $$| $$  | $$ \\  $$$$/ | $$\\ $$$$ \\  $$$$/ ")
+	color.Green("\t\t| $$ | $$ | $$| $$  | $$  >$$  $$ | $$ \\ $$$  >$$  $$ ")
+	color.Green("\t\t| $$ | $$ | $$|  $$$$$$/ /$$/\\  $$|  $$$$$$/ /$$/\\  $$")
+	color.Green("\t\t|__/ |__/ |__/ \\______/ |__/  \\__/ \\______/ |__/  \\__/")
+	fmt.Println()
+	color.Green("\t\t[+] mulef - LinkedIn Employee Finder - v1.0")
+	color.Green("\t\t[+] github@mux0x")
+	fmt.Println()
 
+	if err := runCommand(os.Args[1:]); err != nil {
+		if err != flag.ErrHelp {
+			color.Red("[-] %v", err)
+		}
+		os.Exit(1)
+	}
 }
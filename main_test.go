@@ -0,0 +1,209 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func matchedLogins(matches []Match) string {
+	var logins string
+	for _, match := range matches {
+		logins += match.Login + " " + match.Evidence[0].Kind + "\n"
+	}
+	return logins
+}
+
+func TestMatchEmployeeKeywords(t *testing.T) {
+	tests := []struct {
+		name  string
+		users []string
+		repos map[string]string
+		want  string
+	}{
+		{name: "keyword in repositories", users: []string{"alice"}, repos: map[string]string{"alice": `[{"name":"acme-tools"}]`}, want: "alice keyword-repos\n"},
+		{name: "no repositories", users: []string{"alice"}, repos: map[string]string{"alice": ``}},
+		{name: "other repositories", users: []string{"alice"}, repos: map[string]string{"alice": `[{"name":"dotfiles"}]`}},
+		{name: "every user is checked", users: []string{"alice", "asmith"}, repos: map[string]string{"alice": `[{"name":"acme-tools"}]`, "asmith": `[{"name":"acme-site"}]`}, want: "alice keyword-repos\nasmith keyword-repos\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logins []string
+			for _, login := range tt.users {
+				logins = append(logins, `{"login":"`+login+`"}`)
+			}
+			withHTTPClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				path := req.URL.Path
+				switch {
+				case path == "/search/users":
+					return stubResponse(http.StatusOK, `{"total_count":1,"items":[`+strings.Join(logins, ",")+`]}`), nil
+				case path == "/search/code":
+					return stubResponse(http.StatusOK, `{"total_count":0,"items":[]}`), nil
+				case strings.HasSuffix(path, "/repos"):
+					return stubResponse(http.StatusOK, tt.repos[strings.Split(path, "/")[2]]), nil
+				case strings.HasPrefix(path, "/users/"):
+					return stubResponse(http.StatusOK, `{"login":"`+strings.TrimPrefix(path, "/users/")+`"}`), nil
+				}
+				return stubResponse(http.StatusNotFound, `{}`), nil
+			}))
+			matches := matchEmployee(Employee{Name: "Alice Smith"}, matchOptions{Mode: modeKeywords, Keywords: []string{"acme"}, Token: "t"})
+			if got := matchedLogins(matches); got != tt.want {
+				t.Errorf("found %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchEmployeeLocation(t *testing.T) {
+	withHTTPClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/search/users":
+			return stubResponse(http.StatusOK, `{"total_count":1,"items":[{"login":"alice"}]}`), nil
+		case "/users/alice":
+			return stubResponse(http.StatusOK, `{"login":"alice","location":"Berlin, Germany"}`), nil
+		}
+		return stubResponse(http.StatusNotFound, `{}`), nil
+	}))
+
+	tests := []struct {
+		name     string
+		location string
+		want     string
+	}{
+		{name: "same city", location: "Berlin, Germany", want: "alice location\n"},
+		{name: "other city", location: "Munich, Germany"},
+		{name: "no location", location: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := matchEmployee(Employee{Name: "Alice Smith", Location: tt.location}, matchOptions{Mode: modeLocation, Token: "t"})
+			if got := matchedLogins(matches); got != tt.want {
+				t.Errorf("found %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkedInPageCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		first linkedInPage
+		want  int
+	}{
+		{name: "one page", first: linkedInPage{Count: 10, Total: 7}, want: 1},
+		{name: "several pages", first: linkedInPage{Count: 10, Total: 95}, want: 10},
+		{name: "default page size", first: linkedInPage{Total: 25}, want: 3},
+		{name: "capped", first: linkedInPage{Count: 10, Total: 5000}, want: 100},
+		{name: "no total", first: linkedInPage{Count: 10}, want: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := linkedInPageCount(tt.first); got != tt.want {
+				t.Errorf("linkedInPageCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchLinkedInEmployees(t *testing.T) {
+	startPattern := regexp.MustCompile(`start:(\d+)`)
+	tests := []struct {
+		name string
+		// results is the number of people LinkedIn has, total what it reports.
+		results int
+		total   int
+		want    int
+		pages   int
+	}{
+		{name: "total reported", results: 25, total: 25, want: 25, pages: 3},
+		{name: "total missing", results: 25, total: 0, want: 25, pages: 4},
+		{name: "total above the cap", results: 1200, total: 1200, want: linkedInResultCap, pages: 100},
+		{name: "total missing above the cap", results: 1200, total: 0, want: linkedInResultCap, pages: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				pages++
+				start, _ := strconv.Atoi(startPattern.FindStringSubmatch(r.URL.RawQuery)[1])
+				var people []Employee
+				for i := start; i < start+10 && i < tt.results; i++ {
+					people = append(people, Employee{URN: "urn:li:member:" + strconv.Itoa(i), Name: "Person " + strconv.Itoa(i)})
+				}
+				w.Write([]byte(searchResponseJSON(start, tt.total, people...)))
+			}))
+			defer server.Close()
+
+			captured := capturedRequest{Method: http.MethodGet, URL: server.URL + "/voyager/api/graphql?variables=(start:0)", Header: make(http.Header)}
+			got := fetchLinkedInEmployees(captured, searchSlice{})
+			if len(got) != tt.want {
+				t.Errorf("fetchLinkedInEmployees() found %d employees, want %d", len(got), tt.want)
+			}
+			if pages != tt.pages {
+				t.Errorf("fetchLinkedInEmployees() requested %d pages, want %d", pages, tt.pages)
+			}
+		})
+	}
+}
+
+func TestProfileEvidenceCommitEmail(t *testing.T) {
+	pushEvents := `[
+		{"type":"WatchEvent","repo":{"name":"acme/site"}},
+		{"type":"PushEvent","repo":{"name":"alice/dotfiles"},"payload":{"commits":[{"sha":"111","author":{"email":"alice@example.org"}}]}},
+		{"type":"PushEvent","repo":{"name":"alice/site"},"payload":{"commits":[{"sha":"222","author":{"email":"Alice@ACME.com"}}]}}
+	]`
+	tests := []struct {
+		name        string
+		domains     []string
+		events      string
+		status      int
+		want        []Evidence
+		wantFetches int
+	}{
+		{
+			name:    "corporate commit email",
+			domains: []string{"@acme.com"},
+			events:  pushEvents,
+			want: []Evidence{{
+				Kind:   evidenceCommitEmail,
+				Detail: "commit email Alice@ACME.com in alice/site",
+				URL:    "https://github.com/alice/site/commit/222",
+			}},
+			wantFetches: 1,
+		},
+		{name: "personal commit emails", domains: []string{"acme.com"}, events: `[{"type":"PushEvent","repo":{"name":"alice/dotfiles"},"payload":{"commits":[{"sha":"111","author":{"email":"alice@example.org"}}]}}]`, wantFetches: 1},
+		{name: "events unavailable", domains: []string{"acme.com"}, status: http.StatusNotFound, events: `{"message":"Not Found"}`, wantFetches: 1},
+		{name: "no domains", events: pushEvents},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetches := 0
+			withHTTPClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				if req.URL.Path != "/users/alice/events/public" {
+					return stubResponse(http.StatusNotFound, `{}`), nil
+				}
+				fetches++
+				status := tt.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				resp := stubResponse(status, tt.events)
+				resp.Header.Set(cacheStatusHeader, "hit")
+				return resp, nil
+			}))
+
+			candidate := &githubCandidate{User: githubUserInfo{Login: "alice", HTMLURL: "https://github.com/alice"}}
+			got := profileEvidence(candidate, matchOptions{Token: "t", Domains: tt.domains})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("profileEvidence() = %+v, want %+v", got, tt.want)
+			}
+			if fetches != tt.wantFetches {
+				t.Errorf("fetched the events %d times, want %d", fetches, tt.wantFetches)
+			}
+		})
+	}
+}
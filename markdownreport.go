@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Confidence tiers of a match, derived from its score.
+const (
+	tierHigh   = "high"
+	tierMedium = "medium"
+	tierLow    = "low"
+)
+
+// tierThresholds are the lowest scores of the high and medium tiers. With the
+// default weights an org membership alone is high; a corporate email, the
+// company field or a keyword in the profile or code is medium; a keyword in
+// repositories or a location alone is low. Signals add up, so a location and
+// a company field hit together are high.
+type tierThresholds struct {
+	High   float64
+	Medium float64
+}
+
+var defaultTierThresholds = tierThresholds{High: 5, Medium: 3}
+
+func (t tierThresholds) tier(score float64) string {
+	switch {
+	case score >= t.High:
+		return tierHigh
+	case score >= t.Medium:
+		return tierMedium
+	}
+	return tierLow
+}
+
+// exposureSources describes, for a client, what each kind of evidence says
+// about how the company is exposed. Location matches expose nothing.
+var exposureSources = []struct {
+	Kind        string
+	Description string
+}{
+	{evidenceEmailDomain, "Corporate email address published on the GitHub profile"},
+	{evidenceCommitEmail, "Corporate email address in public commits"},
+	{evidenceKeywordCode, "Company keywords in public code"},
+	{evidenceKeywordRepos, "Company keywords in public repositories"},
+	{evidenceKeywordProfile, "Company named in the GitHub profile"},
+	{evidenceCompanyField, "Company set as the employer on the GitHub profile"},
+	{evidenceOrgMember, "Public member of a company GitHub organization"},
+}
+
+func isExposureSource(kind string) bool {
+	for _, source := range exposureSources {
+		if source.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "|", `\|`, "<", `\<`, ">", `\>`)
+
+// writeMarkdownReport writes results as Markdown meant to be pasted into a
+// pentest report: a summary, the sources of exposure and the accounts grouped
+// by confidence tier with their evidence. Rejected matches are left out.
+func writeMarkdownReport(filename string, results Results, thresholds tierThresholds) error {
+	grouped := matchesByEmployee(results.Matches)
+	tiers := make(map[string][]Match)
+	exposed := make(map[string]map[string]bool)
+	matched := make(map[string]bool)
+	for _, employee := range matchedEmployees(results, grouped) {
+		for _, match := range grouped[employeeKey(employee)] {
+			if match.Decision == decisionRejected {
+				continue
+			}
+			matched[employeeKey(employee)] = true
+			tier := thresholds.tier(match.Score)
+			tiers[tier] = append(tiers[tier], match)
+			for _, evidence := range match.Evidence {
+				if !isExposureSource(evidence.Kind) {
+					continue
+				}
+				if exposed[evidence.Kind] == nil {
+					exposed[evidence.Kind] = make(map[string]bool)
+				}
+				exposed[evidence.Kind][match.Login] = true
+			}
+		}
+	}
+	for _, matches := range tiers {
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].Score > matches[j].Score
+		})
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+
+	fmt.Fprintf(w, "# GitHub Accounts of Employees\n\n")
+	fmt.Fprintf(w, "Run on %s in %s mode", results.StartedAt.Format("2006-01-02"), results.Mode)
+	if len(results.Keywords) > 0 {
+		fmt.Fprintf(w, " with the keywords %s", markdownEscaper.Replace(strings.Join(results.Keywords, ", ")))
+	}
+	fmt.Fprintf(w, ".\n\n## Summary\n\n")
+	fmt.Fprintf(w, "| | |\n|---|---|\n")
+	fmt.Fprintf(w, "| Employees | %d |\n", len(results.Employees))
+	fmt.Fprintf(w, "| Employees with GitHub accounts | %d |\n", len(matched))
+	fmt.Fprintf(w, "| High confidence accounts | %d |\n", len(tiers[tierHigh]))
+	fmt.Fprintf(w, "| Medium confidence accounts | %d |\n", len(tiers[tierMedium]))
+	fmt.Fprintf(w, "| Low confidence accounts | %d |\n", len(tiers[tierLow]))
+
+	fmt.Fprintf(w, "\n## Sources of Exposure\n\n")
+	if len(exposed) == 0 {
+		fmt.Fprintf(w, "No account exposes the company beyond its owner's name and location.\n")
+	} else {
+		fmt.Fprintf(w, "| Source | Accounts |\n|---|---|\n")
+		for _, source := range exposureSources {
+			if count := len(exposed[source.Kind]); count > 0 {
+				fmt.Fprintf(w, "| %s | %d |\n", source.Description, count)
+			}
+		}
+	}
+
+	for _, tier := range []struct {
+		name  string
+		title string
+	}{
+		{tierHigh, fmt.Sprintf("High Confidence (score %g or more)", thresholds.High)},
+		{tierMedium, fmt.Sprintf("Medium Confidence (score %g to below %g)", thresholds.Medium, thresholds.High)},
+		{tierLow, fmt.Sprintf("Low Confidence (score below %g)", thresholds.Medium)},
+	} {
+		if len(tiers[tier.name]) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n## %s\n", tier.title)
+		for _, match := range tiers[tier.name] {
+			writeMarkdownMatch(w, match)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func writeMarkdownMatch(w *bufio.Writer, match Match) {
+	employee := match.Employee
+	fmt.Fprintf(w, "\n### %s\n\n", markdownEscaper.Replace(employee.Name))
+	for _, detail := range [][2]string{
+		{"Title", employee.Title},
+		{"Location", employee.Location},
+		{"Employment", employee.Status},
+	} {
+		if detail[1] != "" {
+			fmt.Fprintf(w, "- %s: %s\n", detail[0], markdownEscaper.Replace(detail[1]))
+		}
+	}
+	if employee.ProfileURL != "" {
+		fmt.Fprintf(w, "- LinkedIn: <%s>\n", employee.ProfileURL)
+	}
+
+	fmt.Fprintf(w, "- GitHub: [%s](%s), score %g", markdownEscaper.Replace(match.Login), match.ProfileURL, match.Score)
+	if match.Decision != "" {
+		fmt.Fprintf(w, ", %s", match.Decision)
+	}
+	if match.Ambiguous {
+		fmt.Fprintf(w, ", ambiguous with %s", markdownEscaper.Replace(strings.Join(match.Contenders, ", ")))
+	}
+	fmt.Fprintf(w, "\n\n")
+
+	fmt.Fprintf(w, "| Evidence | Detail | URL |\n|---|---|---|\n")
+	for _, evidence := range match.Evidence {
+		fmt.Fprintf(w, "| %s | %s | %s |\n", evidence.Kind, markdownEscaper.Replace(evidence.Detail), strings.ReplaceAll(evidence.URL, "|", "%7C"))
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTierThresholds(t *testing.T) {
+	tests := []struct {
+		score float64
+		want  string
+	}{
+		{score: 0, want: tierLow},
+		{score: 2.9, want: tierLow},
+		{score: 3, want: tierMedium},
+		{score: 4.5, want: tierMedium},
+		{score: 5, want: tierHigh},
+		{score: 12, want: tierHigh},
+	}
+	for _, tt := range tests {
+		if got := defaultTierThresholds.tier(tt.score); got != tt.want {
+			t.Errorf("tier(%g) = %s, want %s", tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultWeightTiers(t *testing.T) {
+	tests := []struct {
+		kinds []string
+		want  string
+	}{
+		{kinds: []string{evidenceOrgMember}, want: tierHigh},
+		{kinds: []string{evidenceEmailDomain}, want: tierMedium},
+		{kinds: []string{evidenceCommitEmail}, want: tierMedium},
+		{kinds: []string{evidenceCompanyField}, want: tierMedium},
+		{kinds: []string{evidenceKeywordProfile}, want: tierMedium},
+		{kinds: []string{evidenceKeywordCode}, want: tierMedium},
+		{kinds: []string{evidenceKeywordRepos}, want: tierLow},
+		{kinds: []string{evidenceLocation}, want: tierLow},
+		{kinds: []string{evidenceLocation, evidenceCompanyField}, want: tierHigh},
+	}
+	for _, tt := range tests {
+		var evidence []Evidence
+		for _, kind := range tt.kinds {
+			evidence = append(evidence, Evidence{Kind: kind})
+		}
+		if got := defaultTierThresholds.tier(matchOptions{}.score(evidence)); got != tt.want {
+			t.Errorf("tier of %v = %s, want %s", tt.kinds, got, tt.want)
+		}
+	}
+}
+
+func TestWriteMarkdownReport(t *testing.T) {
+	alice := Employee{Name: "Alice_Smith", Location: "Berlin"}
+	bob := Employee{Name: "Bob", Location: "Paris"}
+	carol := Employee{Name: "Carol", Location: "Rome"}
+	results := Results{
+		Mode:      modeKeywords,
+		Keywords:  []string{"acme"},
+		Employees: []Employee{alice, bob, carol},
+		Matches: []Match{
+			{Employee: alice, Login: "alice", Score: 5, Evidence: []Evidence{{Kind: evidenceOrgMember, Detail: "acme|corp"}, {Kind: evidenceCommitEmail, Detail: "commit email alice@acme.com in alice/site"}}},
+			{Employee: bob, Login: "bob", Score: 3, Evidence: []Evidence{{Kind: evidenceKeywordRepos, Detail: "acme-tools"}}},
+			{Employee: bob, Login: "bob2", Score: 1, Evidence: []Evidence{{Kind: evidenceLocation, Detail: "Paris"}}},
+			{Employee: carol, Login: "carol", Score: 9, Decision: decisionRejected, Evidence: []Evidence{{Kind: evidenceEmailDomain}}},
+		},
+	}
+
+	filename := filepath.Join(t.TempDir(), "report.md")
+	if err := writeMarkdownReport(filename, results, defaultTierThresholds); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := string(data)
+
+	for _, want := range []string{
+		"| Employees with GitHub accounts | 2 |",
+		"| High confidence accounts | 1 |",
+		"| Medium confidence accounts | 1 |",
+		"| Low confidence accounts | 1 |",
+		"| Public member of a company GitHub organization | 1 |",
+		"| Company keywords in public repositories | 1 |",
+		"| Corporate email address in public commits | 1 |",
+		"### Alice\\_Smith",
+		"acme\\|corp",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+	for _, unwanted := range []string{"carol", "Corporate email address published on the GitHub profile"} {
+		if strings.Contains(report, unwanted) {
+			t.Errorf("report contains the rejected match: %q", unwanted)
+		}
+	}
+
+	high := strings.Index(report, "## High Confidence")
+	medium := strings.Index(report, "## Medium Confidence")
+	low := strings.Index(report, "## Low Confidence")
+	if high < 0 || !(high < medium && medium < low) {
+		t.Errorf("tiers are missing or out of order: %d %d %d", high, medium, low)
+	}
+}
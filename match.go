@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// Matching modes.
+const (
+	modeLocation = "location"
+	modeKeywords = "keywords"
+)
+
+// Kinds of evidence linking a GitHub account to an employee.
+const (
+	evidenceLocation       = "location"
+	evidenceKeywordRepos   = "keyword-repos"
+	evidenceKeywordProfile = "keyword-profile"
+	evidenceKeywordCode    = "keyword-code"
+	evidenceEmailDomain    = "email-domain"
+	evidenceCommitEmail    = "commit-email"
+	evidenceCompanyField   = "company-field"
+	evidenceOrgMember      = "org-member"
+)
+
+// defaultScoringWeights is how much each kind of evidence adds to the score
+// of a match. A config profile can override them.
+var defaultScoringWeights = map[string]float64{
+	evidenceLocation:       2,
+	evidenceKeywordRepos:   2,
+	evidenceKeywordProfile: 3,
+	evidenceKeywordCode:    3,
+	evidenceCompanyField:   3,
+	evidenceEmailDomain:    4,
+	evidenceCommitEmail:    4,
+	evidenceOrgMember:      5,
+}
+
+// scoringWeights merges overrides into the default weights.
+func scoringWeights(overrides map[string]float64) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	for kind, weight := range defaultScoringWeights {
+		weights[kind] = weight
+	}
+	for kind, weight := range overrides {
+		if _, ok := defaultScoringWeights[kind]; !ok {
+			return nil, fmt.Errorf("unknown evidence kind %q", kind)
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("weight of %s can not be negative", kind)
+		}
+		weights[kind] = weight
+	}
+	return weights, nil
+}
+
+type matchOptions struct {
+	Mode     string
+	Keywords []string
+	Token    string
+	Threads  int
+	Backend  string
+
+	// Set from a config profile.
+	Aliases   []string
+	Domains   []string
+	Orgs      []string
+	Locations []string
+	Weights   map[string]float64
+
+	// Context, when set, stops the run once it is cancelled. Employees being
+	// matched at that moment are finished.
+	Context context.Context
+	// Progress, when set, is called after each employee with the number of
+	// employees done so far.
+	Progress func(done int, total int)
+}
+
+// runContext is Context, or context.Background for a run that can not be
+// cancelled.
+func (o matchOptions) runContext() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+// score adds up the weights of the evidence of a match.
+func (o matchOptions) score(evidence []Evidence) float64 {
+	weights := o.Weights
+	if weights == nil {
+		weights = defaultScoringWeights
+	}
+	score := 0.0
+	for _, e := range evidence {
+		score += weights[e.Kind]
+	}
+	return score
+}
+
+// Evidence is one reason to believe a GitHub account belongs to an employee.
+type Evidence struct {
+	Kind    string `json:"kind"`
+	Keyword string `json:"keyword,omitempty"`
+	Detail  string `json:"detail"`
+	URL     string `json:"url,omitempty"`
+}
+
+// Match is a GitHub account found for an employee.
+type Match struct {
+	Employee   Employee   `json:"employee"`
+	Login      string     `json:"login"`
+	ProfileURL string     `json:"profile_url"`
+	Name       string     `json:"name,omitempty"`
+	Location   string     `json:"location,omitempty"`
+	AvatarURL  string     `json:"avatar_url,omitempty"`
+	Score      float64    `json:"score"`
+	Evidence   []Evidence `json:"evidence"`
+
+	// Set when the login was also found for other employees.
+	Contenders []string `json:"contenders,omitempty"`
+	Ambiguous  bool     `json:"ambiguous,omitempty"`
+
+	// Decision is set by mulef review: confirmed, rejected or unsure.
+	Decision string `json:"decision,omitempty"`
+}
+
+// Results is everything one matching run produced. It is what `mulef github
+// match` writes with -results and what `mulef report` reads.
+type Results struct {
+	Engagement string             `json:"engagement,omitempty"`
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt time.Time          `json:"finished_at"`
+	Mode       string             `json:"mode"`
+	Keywords   []string           `json:"keywords,omitempty"`
+	Weights    map[string]float64 `json:"weights,omitempty"`
+	Employees  []Employee         `json:"employees"`
+	Matches    []Match            `json:"matches"`
+
+	// Discarded are matches of logins that were assigned to another
+	// employee, kept for manual review.
+	Discarded []Match `json:"discarded,omitempty"`
+}
+
+// matchEmployees runs matchEmployee for every employee on options.Threads
+// workers. onMatch, when set, is called for each match as soon as it is found.
+func matchEmployees(employees []Employee, options matchOptions, onMatch func(Match)) []Match {
+	threadCount := options.Threads
+	if threadCount < 1 {
+		threadCount = 1
+	}
+
+	var mu sync.Mutex
+	var matches []Match
+	var wg sync.WaitGroup
+	employeeChan := make(chan Employee)
+	done := 0
+
+	for i := 0; i < threadCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range employeeChan {
+				found := matchEmployee(e, options)
+				mu.Lock()
+				for _, match := range found {
+					matches = append(matches, match)
+					if onMatch != nil {
+						onMatch(match)
+					}
+				}
+				done++
+				employeesMetric.add(1)
+				if options.Progress != nil {
+					options.Progress(done, len(employees))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var stop <-chan struct{}
+	if options.Context != nil {
+		stop = options.Context.Done()
+	}
+feed:
+	for _, e := range employees {
+		select {
+		case employeeChan <- e:
+		case <-stop:
+			break feed
+		}
+	}
+	close(employeeChan)
+	wg.Wait()
+
+	return matches
+}
+
+func loadResults(filename string) (Results, error) {
+	var results Results
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return results, err
+	}
+	err = json.Unmarshal(data, &results)
+	return results, err
+}
+
+func saveResults(filename string, results Results) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0644)
+}
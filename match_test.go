@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMatchEmployees(t *testing.T) {
+	employees := []Employee{
+		{Name: "Alice Smith", Location: "Berlin"},
+		{Name: "Bob Jones", Location: "Berlin"},
+		{Name: "Carol White", Location: "Berlin"},
+		{Name: "Dan Brown", Location: "Berlin"},
+	}
+	tests := []struct {
+		name    string
+		threads int
+		// Every GitHub request pauses for 500ms, so each employee takes a
+		// second and four of them four seconds one after the other.
+		maxDuration time.Duration
+	}{
+		{name: "default", threads: 0},
+		{name: "one thread per employee", threads: 4, maxDuration: 2500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			searches := make(map[string]int)
+			withHTTPClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				q := req.URL.Query().Get("q")
+				switch req.URL.Path {
+				case "/search/users":
+					mu.Lock()
+					searches[q]++
+					mu.Unlock()
+					return stubResponse(http.StatusOK, `{"total_count":1,"items":[{"login":"`+q[:1]+`"}]}`), nil
+				case "/users/A", "/users/B", "/users/C", "/users/D":
+					return stubResponse(http.StatusOK, `{"login":"`+req.URL.Path[7:]+`","location":"Berlin"}`), nil
+				}
+				return stubResponse(http.StatusNotFound, `{}`), nil
+			}))
+
+			var found []string
+			start := time.Now()
+			matches := matchEmployees(employees, matchOptions{Mode: modeLocation, Token: "t", Threads: tt.threads}, func(match Match) {
+				found = append(found, match.Login)
+			})
+			if elapsed := time.Since(start); tt.maxDuration > 0 && elapsed > tt.maxDuration {
+				t.Errorf("took %v, want at most %v", elapsed, tt.maxDuration)
+			}
+			for _, employee := range employees {
+				if searches[employee.Name] != 1 {
+					t.Errorf("searches = %v, want one for each employee", searches)
+					break
+				}
+			}
+			if len(searches) != len(employees) {
+				t.Errorf("searches = %v, want one for each employee", searches)
+			}
+			if len(matches) != len(employees) || len(found) != len(employees) {
+				t.Errorf("matches = %+v, found %v, want one for each employee", matches, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric is a counter or gauge, with one value per combination of label
+// values, exposed in the Prometheus text format by mulef serve and watch.
+type metric struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// metrics lists every metric in the order they are exposed.
+var metrics []*metric
+
+func newMetric(name string, kind string, help string, labels ...string) *metric {
+	m := &metric{name: name, help: help, kind: kind, labels: labels, values: make(map[string]float64)}
+	metrics = append(metrics, m)
+	return m
+}
+
+var (
+	linkedInPagesMetric = newMetric("mulef_linkedin_pages_total", "counter",
+		"LinkedIn search pages requested, by result (ok, error).", "result")
+	employeesMetric = newMetric("mulef_employees_processed_total", "counter",
+		"Employees looked up on GitHub.")
+	githubRequestsMetric = newMetric("mulef_github_requests_total", "counter",
+		"Requests sent to the GitHub API, by endpoint and HTTP status. Answers from the cache are not counted.", "endpoint", "status")
+	githubCacheMetric = newMetric("mulef_github_cache_responses_total", "counter",
+		"GitHub API reads by cache result (hit, revalidated, miss).", "result")
+	rateLimitRemainingMetric = newMetric("mulef_github_rate_limit_remaining", "gauge",
+		"Requests left in the current GitHub rate limit window, by resource.", "resource")
+	throttlePausesMetric = newMetric("mulef_github_throttle_pauses_total", "counter",
+		"Fixed pauses taken between GitHub requests to stay clear of the secondary rate limits.")
+	rateLimitWaitsMetric = newMetric("mulef_github_rate_limit_waits_total", "counter",
+		"Waits for a used up GitHub rate limit to reset.")
+	rateLimitWaitSecondsMetric = newMetric("mulef_github_rate_limit_wait_seconds_total", "counter",
+		"Time spent waiting for GitHub rate limits to reset.")
+	matchesMetric = newMetric("mulef_matches_total", "counter",
+		"GitHub accounts matched, by confidence tier (high, medium, low).", "tier")
+)
+
+func (m *metric) add(value float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[strings.Join(labelValues, "\xff")] += value
+}
+
+func (m *metric) set(value float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[strings.Join(labelValues, "\xff")] = value
+}
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (m *metric) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+	if len(m.labels) == 0 {
+		fmt.Fprintf(w, "%s %s\n", m.name, strconv.FormatFloat(m.values[""], 'g', -1, 64))
+		return
+	}
+	keys := make([]string, 0, len(m.values))
+	for key := range m.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var pairs []string
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, m.labels[i]+`="`+metricLabelEscaper.Replace(value)+`"`)
+		}
+		fmt.Fprintf(w, "%s{%s} %s\n", m.name, strings.Join(pairs, ","), strconv.FormatFloat(m.values[key], 'g', -1, 64))
+	}
+}
+
+// serveMetrics answers GET /metrics.
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// githubEndpoint turns the path of a GitHub API request into its endpoint,
+// leaving out users and organizations so the number of series stays small.
+func githubEndpoint(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "users":
+		return "/users/{user}"
+	case len(parts) == 3 && parts[0] == "users":
+		return "/users/{user}/" + parts[2]
+	case len(parts) == 4 && parts[0] == "users":
+		return "/users/{user}/" + parts[2] + "/" + parts[3]
+	case len(parts) == 4 && parts[0] == "orgs":
+		return "/orgs/{org}/" + parts[2] + "/{user}"
+	case len(parts) == 3 && parts[0] == "orgs":
+		return "/orgs/{org}/" + parts[2]
+	}
+	switch path := "/" + strings.Join(parts, "/"); path {
+	case "/search/users", "/search/code", "/rate_limit", "/graphql":
+		return path
+	}
+	return "other"
+}
+
+// metricsTransport counts the GitHub API requests, the cache results and
+// keeps track of the rate limit left.
+type metricsTransport struct {
+	next http.RoundTripper
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if req.URL.Host != "api.github.com" {
+		return resp, err
+	}
+	endpoint := githubEndpoint(req.URL.Path)
+	if err != nil {
+		githubRequestsMetric.add(1, endpoint, "error")
+		return resp, err
+	}
+
+	status := strconv.Itoa(resp.StatusCode)
+	switch cache := resp.Header.Get(cacheStatusHeader); cache {
+	case "hit":
+		githubCacheMetric.add(1, cache)
+		return resp, nil
+	case "revalidated":
+		// GitHub answered 304, the cache turned it into the stored answer.
+		githubCacheMetric.add(1, cache)
+		status = strconv.Itoa(http.StatusNotModified)
+	case "miss":
+		githubCacheMetric.add(1, cache)
+	}
+	githubRequestsMetric.add(1, endpoint, status)
+	recordRateLimit(resp.Header)
+	return resp, nil
+}
+
+// countMatches adds the matches of a finished run to mulef_matches_total.
+func countMatches(matches []Match) {
+	for _, match := range matches {
+		matchesMetric.add(1, defaultTierThresholds.tier(match.Score))
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGitHubEndpoint(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/users/alice", want: "/users/{user}"},
+		{path: "/users/alice/repos", want: "/users/{user}/repos"},
+		{path: "/users/alice/events/public", want: "/users/{user}/events/public"},
+		{path: "/orgs/acme/members", want: "/orgs/{org}/members"},
+		{path: "/orgs/acme/members/alice", want: "/orgs/{org}/members/{user}"},
+		{path: "/search/users", want: "/search/users"},
+		{path: "/search/code/", want: "/search/code"},
+		{path: "/rate_limit", want: "/rate_limit"},
+		{path: "/graphql", want: "/graphql"},
+		{path: "/repos/acme/site", want: "other"},
+		{path: "/", want: "other"},
+	}
+	for _, tt := range tests {
+		if got := githubEndpoint(tt.path); got != tt.want {
+			t.Errorf("githubEndpoint(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMetricWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric *metric
+		update func(m *metric)
+		want   string
+	}{
+		{
+			name:   "unlabelled counter starts at zero",
+			metric: &metric{name: "mulef_test_total", help: "Test.", kind: "counter", values: make(map[string]float64)},
+			update: func(m *metric) {},
+			want:   "# HELP mulef_test_total Test.\n# TYPE mulef_test_total counter\nmulef_test_total 0\n",
+		},
+		{
+			name:   "labels sorted and escaped",
+			metric: &metric{name: "mulef_test_total", help: "Test.", kind: "counter", labels: []string{"endpoint", "status"}, values: make(map[string]float64)},
+			update: func(m *metric) {
+				m.add(1, "/users/{user}", "200")
+				m.add(2, "/users/{user}", "200")
+				m.add(0.5, `a"b`, "404")
+			},
+			want: "# HELP mulef_test_total Test.\n# TYPE mulef_test_total counter\n" +
+				"mulef_test_total{endpoint=\"/users/{user}\",status=\"200\"} 3\n" +
+				"mulef_test_total{endpoint=\"a\\\"b\",status=\"404\"} 0.5\n",
+		},
+		{
+			name:   "gauge is set",
+			metric: &metric{name: "mulef_test", help: "Test.", kind: "gauge", labels: []string{"resource"}, values: make(map[string]float64)},
+			update: func(m *metric) {
+				m.set(10, "core")
+				m.set(4, "core")
+			},
+			want: "# HELP mulef_test Test.\n# TYPE mulef_test gauge\nmulef_test{resource=\"core\"} 4\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.update(tt.metric)
+			var buf bytes.Buffer
+			tt.metric.write(&buf)
+			if buf.String() != tt.want {
+				t.Errorf("write =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func metricValue(m *metric, labelValues ...string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[strings.Join(labelValues, "\xff")]
+}
+
+func TestMetricsTransport(t *testing.T) {
+	transport := &metricsTransport{next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp := stubResponse(http.StatusOK, `{}`)
+		switch req.URL.Path {
+		case "/users/cached":
+			resp.Header.Set(cacheStatusHeader, "hit")
+		case "/users/alice":
+			resp.Header.Set(cacheStatusHeader, "miss")
+			resp.Header.Set("X-RateLimit-Resource", "core")
+			resp.Header.Set("X-RateLimit-Limit", "5000")
+			resp.Header.Set("X-RateLimit-Remaining", "4321")
+		}
+		return resp, nil
+	})}
+	before := struct{ requests, hits, misses, other float64 }{
+		metricValue(githubRequestsMetric, "/users/{user}", "200"),
+		metricValue(githubCacheMetric, "hit"),
+		metricValue(githubCacheMetric, "miss"),
+		metricValue(githubRequestsMetric, "other", "200"),
+	}
+	for _, url := range []string{
+		"https://api.github.com/users/alice",
+		"https://api.github.com/users/cached",
+		"https://www.linkedin.com/voyager/api/search",
+	} {
+		req, _ := http.NewRequest("GET", url, nil)
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := metricValue(githubRequestsMetric, "/users/{user}", "200") - before.requests; got != 1 {
+		t.Errorf("requests counted = %v, want 1, a cache hit did not reach GitHub", got)
+	}
+	if got := metricValue(githubCacheMetric, "hit") - before.hits; got != 1 {
+		t.Errorf("hits = %v, want 1", got)
+	}
+	if got := metricValue(githubCacheMetric, "miss") - before.misses; got != 1 {
+		t.Errorf("misses = %v, want 1", got)
+	}
+	if got := metricValue(githubRequestsMetric, "other", "200") - before.other; got != 0 {
+		t.Errorf("LinkedIn request counted as GitHub %v times", got)
+	}
+	if got := metricValue(rateLimitRemainingMetric, "core"); got != 4321 {
+		t.Errorf("remaining = %v, want 4321", got)
+	}
+}
+
+func TestCountMatches(t *testing.T) {
+	before := map[string]float64{}
+	for _, tier := range []string{"high", "medium", "low"} {
+		before[tier] = metricValue(matchesMetric, tier)
+	}
+	countMatches([]Match{
+		{Score: defaultTierThresholds.High},
+		{Score: defaultTierThresholds.High + 3},
+		{Score: defaultTierThresholds.Medium},
+		{Score: 0},
+	})
+	want := map[string]float64{"high": 2, "medium": 1, "low": 1}
+	for tier, n := range want {
+		if got := metricValue(matchesMetric, tier) - before[tier]; got != n {
+			t.Errorf("%s matches = %v, want %v", tier, got, n)
+		}
+	}
+}
+
+func TestGitHubPauseMetrics(t *testing.T) {
+	cached := stubResponse(http.StatusOK, `{}`)
+	cached.Header.Set(cacheStatusHeader, "hit")
+	usedUp := stubResponse(http.StatusOK, `{}`)
+	usedUp.Header = rateLimitHeader("search", 30, 0, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name              string
+		resp              *http.Response
+		throttles, resets float64
+		minSeconds        float64
+	}{
+		{name: "throttle", resp: stubResponse(http.StatusOK, `{}`), throttles: 1},
+		{name: "no response", throttles: 1},
+		{name: "cache hit", resp: cached},
+		{name: "rate limit used up", resp: usedUp, resets: 1, minSeconds: 0.05},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The wait for the reset counts the time until the run is cancelled.
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			throttles, resets, seconds := metricValue(throttlePausesMetric), metricValue(rateLimitWaitsMetric), metricValue(rateLimitWaitSecondsMetric)
+			githubPause(ctx, tt.resp)
+			if got := metricValue(throttlePausesMetric) - throttles; got != tt.throttles {
+				t.Errorf("throttle pauses = %v, want %v", got, tt.throttles)
+			}
+			if got := metricValue(rateLimitWaitsMetric) - resets; got != tt.resets {
+				t.Errorf("rate limit waits = %v, want %v", got, tt.resets)
+			}
+			if got := metricValue(rateLimitWaitSecondsMetric) - seconds; got < tt.minSeconds || got > 1 || (tt.resets == 0 && got != 0) {
+				t.Errorf("rate limit wait seconds = %v, want at least %v", got, tt.minSeconds)
+			}
+		})
+	}
+}
+
+func TestMetricsTransportRevalidatedRateLimit(t *testing.T) {
+	fetchedAt, revalidatedAt := time.Now().Add(-2*time.Hour), time.Now()
+	calls := 0
+	transport := &metricsTransport{next: &cachingTransport{
+		dir: t.TempDir(),
+		ttl: 0,
+		next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			if req.Header.Get("If-None-Match") == `"v1"` {
+				resp := stubResponse(http.StatusNotModified, "")
+				resp.Header = rateLimitHeader("code_search", 10, 7, revalidatedAt.Add(time.Minute))
+				return resp, nil
+			}
+			resp := stubResponse(http.StatusOK, `{"login":"alice"}`)
+			resp.Header = rateLimitHeader("code_search", 10, 9, fetchedAt.Add(time.Minute))
+			resp.Header.Set("ETag", `"v1"`)
+			return resp, nil
+		}),
+	}}
+
+	var resp *http.Response
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "https://api.github.com/users/alice", nil)
+		var err error
+		if resp, err = transport.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if calls != 2 || resp.Header.Get(cacheStatusHeader) != "revalidated" {
+		t.Fatalf("%d calls, cache %q, want the second answer revalidated", calls, resp.Header.Get(cacheStatusHeader))
+	}
+	if got := resp.Header.Get("X-RateLimit-Remaining"); got != "7" {
+		t.Errorf("X-RateLimit-Remaining = %q, want the 304's 7", got)
+	}
+	if got, want := resp.Header.Get("X-RateLimit-Reset"), strconv.FormatInt(revalidatedAt.Add(time.Minute).Unix(), 10); got != want {
+		t.Errorf("X-RateLimit-Reset = %q, want the 304's %q", got, want)
+	}
+	if got := metricValue(rateLimitRemainingMetric, "code_search"); got != 7 {
+		t.Errorf("remaining = %v, want 7", got)
+	}
+}
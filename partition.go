@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// searchFilter is a single voyager query parameter, e.g. geoUrn=103644278.
+type searchFilter struct {
+	Key   string
+	Value string
+}
+
+// searchSlice narrows the captured LinkedIn search down to a subset of its
+// results by rewriting the voyager query variables.
+type searchSlice struct {
+	Filters  []searchFilter
+	Keywords string
+}
+
+// empty tells whether the slice leaves the captured search as it is.
+func (s searchSlice) empty() bool {
+	return len(s.Filters) == 0 && s.Keywords == ""
+}
+
+func (s searchSlice) with(key string, value string) searchSlice {
+	next := searchSlice{Keywords: s.Keywords}
+	next.Filters = append(next.Filters, s.Filters...)
+	if key == "keywords" {
+		next.Keywords = value
+	} else {
+		next.Filters = append(next.Filters, searchFilter{Key: key, Value: value})
+	}
+	return next
+}
+
+// apply rewrites the variables of a voyager search URL so it only returns the
+// results of this slice.
+func (s searchSlice) apply(rawURL string) string {
+	if len(s.Filters) == 0 && s.Keywords == "" {
+		return rawURL
+	}
+	for _, filter := range s.Filters {
+		rawURL = setQueryParameter(rawURL, filter.Key, filter.Value)
+	}
+	if s.Keywords != "" {
+		rawURL = setSearchKeywords(rawURL, s.Keywords)
+	}
+	return rawURL
+}
+
+func (s searchSlice) describe() string {
+	var parts []string
+	for _, filter := range s.Filters {
+		parts = append(parts, filter.Key+"="+filter.Value)
+	}
+	if s.Keywords != "" {
+		parts = append(parts, "keywords="+s.Keywords)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " for " + strings.Join(parts, ", ")
+}
+
+// setQueryParameter sets key to value in the queryParameters list of a
+// voyager search URL, replacing any value the captured request already had.
+func setQueryParameter(rawURL string, key string, value string) string {
+	param := "(key:" + key + ",value:List(" + value + "))"
+	existing := regexp.MustCompile(`\(key:` + regexp.QuoteMeta(key) + `,value:List\([^)]*\)\)`)
+	if existing.MatchString(rawURL) {
+		return existing.ReplaceAllLiteralString(rawURL, param)
+	}
+	if strings.Contains(rawURL, "queryParameters:List(") {
+		return strings.Replace(rawURL, "queryParameters:List(", "queryParameters:List("+param+",", 1)
+	}
+	return strings.Replace(rawURL, "query:(", "query:(queryParameters:List("+param+"),", 1)
+}
+
+var searchKeywordsPattern = regexp.MustCompile(`keywords:([^,)]*)`)
+
+// setSearchKeywords adds keywords to the keywords of a voyager search URL,
+// keeping those the captured request already searched for.
+func setSearchKeywords(rawURL string, keywords string) string {
+	if match := searchKeywordsPattern.FindStringSubmatchIndex(rawURL); match != nil {
+		if captured := rawURL[match[2]:match[3]]; captured != "" {
+			keywords = captured + "%20" + keywords
+		}
+		return rawURL[:match[0]] + "keywords:" + keywords + rawURL[match[1]:]
+	}
+	return strings.Replace(rawURL, "query:(", "query:(keywords:"+keywords+",", 1)
+}
+
+// partitionFacet is a voyager filter the company search is split on, with
+// every value to try.
+type partitionFacet struct {
+	Key    string
+	Values []string
+}
+
+// facetAliases maps the short facet names accepted on the command line to
+// the voyager query parameter names.
+var facetAliases = map[string]string{
+	"geo":     "geoUrn",
+	"company": "currentCompany",
+	"school":  "schoolFilter",
+	"keyword": "keywords",
+}
+
+// builtinFacetValues lists the facets whose values are fixed by LinkedIn and
+// do not need to be supplied with -partition-values. The keywords values are
+// single-letter keyword searches, not first-letter filters, so the slices
+// overlap and may miss people. They are the last resort of -partition auto.
+var builtinFacetValues = map[string][]string{
+	"function":  numberedValues(1, 26),
+	"seniority": numberedValues(1, 10),
+	"keywords":  strings.Split("abcdefghijklmnopqrstuvwxyz", ""),
+}
+
+// autoFacets is the split order used by -partition auto after any facet that
+// was given explicit values.
+var autoFacets = []string{"function", "seniority", "keywords"}
+
+func numberedValues(from int, to int) []string {
+	var values []string
+	for i := from; i <= to; i++ {
+		values = append(values, strconv.Itoa(i))
+	}
+	return values
+}
+
+func canonicalFacet(name string) string {
+	name = strings.TrimSpace(name)
+	if alias, ok := facetAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// facetValuesFlag collects repeated -partition-values key=v1,v2 flags.
+type facetValuesFlag map[string][]string
+
+func (f facetValuesFlag) String() string {
+	var parts []string
+	for key, values := range f {
+		parts = append(parts, key+"="+strings.Join(values, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (f facetValuesFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected facet=value1,value2, got %q", value)
+	}
+	key := canonicalFacet(parts[0])
+	for _, v := range strings.Split(parts[1], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			f[key] = append(f[key], v)
+		}
+	}
+	return nil
+}
+
+// parsePartitionFacets turns the -partition order into facets. "auto" splits
+// on every facet given values with -partition-values, then on the built-in
+// ones.
+func parsePartitionFacets(order string, values facetValuesFlag) ([]partitionFacet, error) {
+	var names []string
+	if order == "auto" {
+		for key := range values {
+			names = append(names, key)
+		}
+		sort.Strings(names)
+		for _, key := range autoFacets {
+			if _, ok := values[key]; !ok {
+				names = append(names, key)
+			}
+		}
+	} else {
+		for _, name := range strings.Split(order, ",") {
+			if name = canonicalFacet(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	var facets []partitionFacet
+	for _, name := range names {
+		if name == "currentCompany" {
+			return nil, errors.New("facet currentCompany can not split the search, each slice would replace the company being searched")
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("facet %s listed twice", name)
+		}
+		seen[name] = true
+
+		facetValues := values[name]
+		if len(facetValues) == 0 {
+			facetValues = builtinFacetValues[name]
+		}
+		if len(facetValues) == 0 {
+			return nil, fmt.Errorf("facet %s needs values, pass them with -partition-values %s=...", name, name)
+		}
+		facets = append(facets, partitionFacet{Key: name, Values: facetValues})
+	}
+	return facets, nil
+}
+
+// employeeKey identifies an employee across overlapping search slices.
+func employeeKey(employee Employee) string {
+	if employee.URN != "" {
+		return employee.URN
+	}
+	return employee.Name + "|" + employee.Location
+}
+
+// fetchPartitionedEmployees fetches the captured search and, whenever a slice
+// of it is larger than LinkedIn's result cap, splits it on the next facet. The
+// employees of every slice are merged and deduplicated by URN.
+func fetchPartitionedEmployees(captured capturedRequest, facets []partitionFacet) []Employee {
+	seen := make(map[string]bool)
+	var employees []Employee
+
+	var walk func(slice searchSlice, depth int)
+	walk = func(slice searchSlice, depth int) {
+		first, err := fetchLinkedInPage(captured, 0, slice)
+		if err != nil {
+			color.Red("[-] Can not fetch LinkedIn page%s: %v", slice.describe(), err)
+			return
+		}
+		if first.Total > linkedInResultCap && depth < len(facets) {
+			facet := facets[depth]
+			color.Cyan("[+] %d results%s, splitting by %s", first.Total, slice.describe(), facet.Key)
+			for _, value := range facet.Values {
+				walk(slice.with(facet.Key, value), depth+1)
+			}
+			return
+		}
+		if first.Total > linkedInResultCap {
+			color.Yellow("[!] LinkedIn reports %d results%s but only returns the first %d, the rest will be missed", first.Total, slice.describe(), linkedInResultCap)
+		}
+
+		for _, employee := range collectLinkedInPages(captured, slice, first) {
+			key := employeeKey(employee)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			employees = append(employees, employee)
+		}
+	}
+	walk(searchSlice{}, 0)
+
+	return employees
+}
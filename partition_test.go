@@ -0,0 +1,231 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetQueryParameter(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		key   string
+		value string
+		want  string
+	}{
+		{
+			name:  "replaces existing value",
+			url:   "query:(flagshipSearchIntent:SEARCH_SRP,queryParameters:List((key:geoUrn,value:List(1)),(key:resultType,value:List(PEOPLE))))",
+			key:   "geoUrn",
+			value: "103644278",
+			want:  "query:(flagshipSearchIntent:SEARCH_SRP,queryParameters:List((key:geoUrn,value:List(103644278)),(key:resultType,value:List(PEOPLE))))",
+		},
+		{
+			name:  "adds to existing list",
+			url:   "query:(queryParameters:List((key:resultType,value:List(PEOPLE))))",
+			key:   "function",
+			value: "8",
+			want:  "query:(queryParameters:List((key:function,value:List(8)),(key:resultType,value:List(PEOPLE))))",
+		},
+		{
+			name:  "creates list",
+			url:   "query:(flagshipSearchIntent:SEARCH_SRP)",
+			key:   "seniority",
+			value: "3",
+			want:  "query:(queryParameters:List((key:seniority,value:List(3))),flagshipSearchIntent:SEARCH_SRP)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := setQueryParameter(tt.url, tt.key, tt.value); got != tt.want {
+				t.Errorf("setQueryParameter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetSearchKeywords(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		keywords string
+		want     string
+	}{
+		{
+			name:     "adds to captured keywords",
+			url:      "variables=(start:0,query:(keywords:engineer,flagshipSearchIntent:SEARCH_SRP))",
+			keywords: "a",
+			want:     "variables=(start:0,query:(keywords:engineer%20a,flagshipSearchIntent:SEARCH_SRP))",
+		},
+		{
+			name:     "empty captured keywords",
+			url:      "variables=(start:0,query:(keywords:,flagshipSearchIntent:SEARCH_SRP))",
+			keywords: "a",
+			want:     "variables=(start:0,query:(keywords:a,flagshipSearchIntent:SEARCH_SRP))",
+		},
+		{
+			name:     "no keywords captured",
+			url:      "variables=(start:0,query:(flagshipSearchIntent:SEARCH_SRP))",
+			keywords: "a",
+			want:     "variables=(start:0,query:(keywords:a,flagshipSearchIntent:SEARCH_SRP))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := setSearchKeywords(tt.url, tt.keywords); got != tt.want {
+				t.Errorf("setSearchKeywords() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchSliceApply(t *testing.T) {
+	url := "https://www.linkedin.com/voyager/api/graphql?variables=(start:0,query:(queryParameters:List((key:resultType,value:List(PEOPLE)))))"
+	tests := []struct {
+		name  string
+		slice searchSlice
+		want  string
+	}{
+		{
+			name:  "empty slice leaves url alone",
+			slice: searchSlice{},
+			want:  url,
+		},
+		{
+			name:  "nested filters",
+			slice: searchSlice{}.with("function", "8").with("seniority", "3"),
+			want:  "https://www.linkedin.com/voyager/api/graphql?variables=(start:0,query:(queryParameters:List((key:seniority,value:List(3)),(key:function,value:List(8)),(key:resultType,value:List(PEOPLE)))))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.slice.apply(url); got != tt.want {
+				t.Errorf("apply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchSliceWithDoesNotShareFilters(t *testing.T) {
+	base := searchSlice{}.with("geoUrn", "1")
+	a := base.with("function", "1")
+	b := base.with("function", "2")
+	if a.Filters[1].Value != "1" || b.Filters[1].Value != "2" {
+		t.Errorf("sibling slices share filters: %v %v", a.Filters, b.Filters)
+	}
+}
+
+func TestParsePartitionFacets(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   string
+		values  facetValuesFlag
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "explicit order with aliases",
+			order: "geo,function",
+			values: facetValuesFlag{
+				"geoUrn": {"1", "2"},
+			},
+			want: []string{"geoUrn", "function"},
+		},
+		{
+			name:  "auto puts supplied facets first",
+			order: "auto",
+			values: facetValuesFlag{
+				"schoolFilter": {"1"},
+				"geoUrn":       {"2"},
+			},
+			want: []string{"geoUrn", "schoolFilter", "function", "seniority", "keywords"},
+		},
+		{
+			name:    "facet without values",
+			order:   "geo",
+			values:  facetValuesFlag{},
+			wantErr: true,
+		},
+		{
+			name:    "company would replace the search",
+			order:   "company",
+			values:  facetValuesFlag{"currentCompany": {"1", "2"}},
+			wantErr: true,
+		},
+		{
+			name:    "company given values in auto",
+			order:   "auto",
+			values:  facetValuesFlag{"currentCompany": {"1", "2"}},
+			wantErr: true,
+		},
+		{
+			name:    "facet listed twice",
+			order:   "function,function",
+			values:  facetValuesFlag{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			facets, err := parsePartitionFacets(tt.order, tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePartitionFacets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, facet := range facets {
+				got = append(got, facet.Key)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePartitionFacets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFacetValuesFlagSet(t *testing.T) {
+	f := facetValuesFlag{}
+	if err := f.Set("geo=1, 2,,3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("geo=4"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1", "2", "3", "4"}; !reflect.DeepEqual(f["geoUrn"], want) {
+		t.Errorf("geoUrn = %v, want %v", f["geoUrn"], want)
+	}
+	for _, bad := range []string{"geo", "=1", "geo="} {
+		if err := f.Set(bad); err == nil {
+			t.Errorf("Set(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestFetchPartitionedEmployeesDropsDuplicates(t *testing.T) {
+	alice := Employee{URN: "urn:li:member:1", Name: "Alice"}
+	bob := Employee{URN: "urn:li:member:2", Name: "Bob"}
+	carol := Employee{URN: "urn:li:member:3", Name: "Carol"}
+	// The single-letter keyword slices overlap: Bob matches both letters.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.RawQuery, "keywords:a"):
+			w.Write([]byte(searchResponseJSON(0, 2, alice, bob)))
+		case strings.Contains(r.URL.RawQuery, "keywords:b"):
+			w.Write([]byte(searchResponseJSON(0, 2, bob, carol)))
+		default:
+			w.Write([]byte(searchResponseJSON(0, 5000)))
+		}
+	}))
+	defer server.Close()
+
+	captured := capturedRequest{Method: http.MethodGet, URL: server.URL + "/voyager/api/graphql?variables=(start:0,query:(flagshipSearchIntent:SEARCH_SRP))", Header: make(http.Header)}
+	var got []string
+	for _, employee := range fetchPartitionedEmployees(captured, []partitionFacet{{Key: "keywords", Values: []string{"a", "b"}}}) {
+		got = append(got, employee.Name)
+	}
+	if want := []string{"Alice", "Bob", "Carol"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fetchPartitionedEmployees() = %v, want %v", got, want)
+	}
+}
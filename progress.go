@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+// progressBarWidth is the number of cells of the progress bar.
+const progressBarWidth = 30
+
+// terminal holds the progress shown by the command line. Log lines written
+// through color are printed above the bar, which is redrawn below them.
+var terminal struct {
+	mu      sync.Mutex
+	enabled bool
+	tty     bool
+	out     io.Writer
+	pending []byte
+	active  *progress
+}
+
+// enableProgress is called by the commands that report their progress: with a
+// bar when stdout is a terminal, with a log line every tenth of the way
+// otherwise.
+func enableProgress() {
+	terminal.enabled = true
+	terminal.tty = isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())
+	if terminal.tty && terminal.out == nil {
+		terminal.out = color.Output
+		color.Output = progressWriter{}
+	}
+}
+
+// progressWriter keeps the progress bar on the last line of the terminal.
+type progressWriter struct{}
+
+func (progressWriter) Write(data []byte) (int, error) {
+	terminal.mu.Lock()
+	defer terminal.mu.Unlock()
+	if terminal.active == nil {
+		pending := terminal.pending
+		terminal.pending = nil
+		_, err := terminal.out.Write(append(pending, data...))
+		return len(data), err
+	}
+
+	// color writes its escape codes apart from the text, so the bar is only
+	// redrawn once a whole line is there.
+	terminal.pending = append(terminal.pending, data...)
+	end := bytes.LastIndexByte(terminal.pending, '\n')
+	if end < 0 {
+		return len(data), nil
+	}
+	lines := terminal.pending[:end+1]
+	terminal.pending = append([]byte(nil), terminal.pending[end+1:]...)
+	_, err := terminal.out.Write(append([]byte("\r\x1b[K"), lines...))
+	terminal.active.draw()
+	return len(data), err
+}
+
+// progress is how far a phase of a run is.
+type progress struct {
+	label string
+	unit  string
+	start time.Time
+	// eta, when set, estimates the time left instead of the pace so far.
+	eta func(done int, total int, elapsed time.Duration) time.Duration
+	// budget, when set, describes the rate limits left.
+	budget func() string
+
+	// Guarded by terminal.mu.
+	done   int
+	total  int
+	logged int
+}
+
+// startProgress starts reporting a phase, or returns nil when the command
+// does not report progress. The methods of a nil progress do nothing.
+func startProgress(label string, unit string, total int) *progress {
+	if !terminal.enabled {
+		return nil
+	}
+	p := &progress{label: label, unit: unit, start: time.Now(), total: total}
+	if terminal.tty {
+		terminal.mu.Lock()
+		terminal.active = p
+		p.draw()
+		terminal.mu.Unlock()
+	}
+	return p
+}
+
+// add counts done more units done out of total more.
+func (p *progress) add(done int, total int) {
+	if p == nil {
+		return
+	}
+	terminal.mu.Lock()
+	p.update(p.done+done, p.total+total)
+}
+
+// set reports done units out of total.
+func (p *progress) set(done int, total int) {
+	if p == nil {
+		return
+	}
+	terminal.mu.Lock()
+	p.update(done, total)
+}
+
+// update is called with terminal.mu held and releases it.
+func (p *progress) update(done int, total int) {
+	p.done, p.total = done, total
+	if terminal.tty {
+		if terminal.active == p {
+			p.draw()
+		}
+		terminal.mu.Unlock()
+		return
+	}
+
+	step := 0
+	if p.total > 0 {
+		step = p.done * 10 / p.total
+	}
+	if step <= p.logged || step >= 10 {
+		terminal.mu.Unlock()
+		return
+	}
+	p.logged = step
+	message := p.status()
+	terminal.mu.Unlock()
+	color.Cyan("[+] " + message)
+}
+
+// finish removes the bar.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	terminal.mu.Lock()
+	defer terminal.mu.Unlock()
+	if terminal.active == p {
+		terminal.active = nil
+		fmt.Fprint(terminal.out, "\r\x1b[K")
+	}
+}
+
+// draw redraws the bar with terminal.mu held.
+func (p *progress) draw() {
+	filled := 0
+	if p.total > 0 {
+		filled = progressBarWidth * p.done / p.total
+	}
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	bar := "[" + strings.Repeat("#", filled) + strings.Repeat(".", progressBarWidth-filled) + "] "
+	reset := ""
+	if !color.NoColor {
+		// The line above may have left its color on.
+		reset = "\x1b[0m"
+	}
+	fmt.Fprint(terminal.out, "\r\x1b[K"+reset+bar+p.status())
+}
+
+// status describes the progress, such as "GitHub 120/400 employees, search
+// 12/30 core 4870/5000, ETA 14m".
+func (p *progress) status() string {
+	parts := []string{fmt.Sprintf("%s %d/%d %s", p.label, p.done, p.total, p.unit)}
+	if p.budget != nil {
+		if budget := p.budget(); budget != "" {
+			parts = append(parts, budget)
+		}
+	}
+	if p.done > 0 && p.done < p.total {
+		elapsed := time.Since(p.start)
+		eta := paceETA(p.done, p.total, elapsed)
+		if p.eta != nil {
+			eta = p.eta(p.done, p.total, elapsed)
+		}
+		parts = append(parts, "ETA "+formatETA(eta))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// paceETA assumes the rest goes at the pace of what is done.
+func paceETA(done int, total int, elapsed time.Duration) time.Duration {
+	if done == 0 {
+		return 0
+	}
+	return time.Duration(float64(elapsed) / float64(done) * float64(total-done))
+}
+
+func formatETA(eta time.Duration) string {
+	switch {
+	case eta < time.Minute:
+		return eta.Round(time.Second).String()
+	case eta < time.Hour:
+		return fmt.Sprintf("%dm%02ds", int(eta.Minutes()), int(eta.Seconds())%60)
+	}
+	return fmt.Sprintf("%dh%02dm", int(eta.Hours()), int(eta.Minutes())%60)
+}
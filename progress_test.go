@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatETA(t *testing.T) {
+	tests := []struct {
+		eta  time.Duration
+		want string
+	}{
+		{eta: 0, want: "0s"},
+		{eta: 1400 * time.Millisecond, want: "1s"},
+		{eta: 59 * time.Second, want: "59s"},
+		{eta: time.Minute, want: "1m00s"},
+		{eta: 14*time.Minute + 5*time.Second, want: "14m05s"},
+		{eta: 2*time.Hour + 3*time.Minute + 50*time.Second, want: "2h03m"},
+	}
+	for _, tt := range tests {
+		if got := formatETA(tt.eta); got != tt.want {
+			t.Errorf("formatETA(%s) = %q, want %q", tt.eta, got, tt.want)
+		}
+	}
+}
+
+func TestPaceETA(t *testing.T) {
+	tests := []struct {
+		done, total int
+		elapsed     time.Duration
+		want        time.Duration
+	}{
+		{done: 0, total: 10, elapsed: time.Minute, want: 0},
+		{done: 5, total: 10, elapsed: time.Minute, want: time.Minute},
+		{done: 1, total: 4, elapsed: 10 * time.Second, want: 30 * time.Second},
+		{done: 10, total: 10, elapsed: time.Minute, want: 0},
+	}
+	for _, tt := range tests {
+		if got := paceETA(tt.done, tt.total, tt.elapsed); got != tt.want {
+			t.Errorf("paceETA(%d, %d, %s) = %s, want %s", tt.done, tt.total, tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestProgressStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		progress progress
+		want     string
+	}{
+		{
+			name:     "not started",
+			progress: progress{label: "GitHub", unit: "employees", total: 400},
+			want:     "GitHub 0/400 employees",
+		},
+		{
+			name:     "budget and eta",
+			progress: progress{label: "GitHub", unit: "employees", done: 120, total: 400, budget: func() string { return "search 12/30" }, eta: func(int, int, time.Duration) time.Duration { return 14 * time.Minute }},
+			want:     "GitHub 120/400 employees, search 12/30, ETA 14m00s",
+		},
+		{
+			name:     "empty budget left out",
+			progress: progress{label: "LinkedIn", unit: "pages", done: 3, total: 3, budget: func() string { return "" }},
+			want:     "LinkedIn 3/3 pages",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.progress.start = time.Now()
+			if got := tt.progress.status(); got != tt.want {
+				t.Errorf("status = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartProgressDisabled(t *testing.T) {
+	// Commands that do not call enableProgress get a nil progress, whose
+	// methods do nothing.
+	if terminal.enabled {
+		t.Skip("progress enabled by another test")
+	}
+	p := startProgress("GitHub", "employees", 10)
+	if p != nil {
+		t.Fatalf("startProgress = %+v, want nil", p)
+	}
+	p.add(1, 0)
+	p.set(2, 10)
+	p.finish()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitUsage is the latest state of a GitHub rate limit resource and the
+// number of requests seen spending it.
+type rateLimitUsage struct {
+	rateLimit
+	requests int
+}
+
+// githubRateLimits tracks the rate limit resources (core, search,
+// code_search, graphql) from the headers of GitHub's answers.
+var githubRateLimits = struct {
+	mu        sync.Mutex
+	resources map[string]rateLimitUsage
+}{resources: make(map[string]rateLimitUsage)}
+
+// recordRateLimit notes the rate limit headers of a GitHub answer.
+func recordRateLimit(header http.Header) {
+	resource := header.Get("X-RateLimit-Resource")
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if resource == "" || err != nil {
+		return
+	}
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, _ := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+
+	githubRateLimits.mu.Lock()
+	usage := githubRateLimits.resources[resource]
+	usage.rateLimit = rateLimit{Limit: limit, Remaining: remaining, Reset: reset}
+	usage.requests++
+	githubRateLimits.resources[resource] = usage
+	githubRateLimits.mu.Unlock()
+	rateLimitRemainingMetric.set(float64(remaining), resource)
+}
+
+// noteTokenStatus starts tracking from the budget /rate_limit reported.
+func noteTokenStatus(status githubTokenStatus) {
+	githubRateLimits.mu.Lock()
+	defer githubRateLimits.mu.Unlock()
+	for resource, limit := range map[string]rateLimit{"core": status.Core, "search": status.Search, "code_search": status.CodeSearch, "graphql": status.GraphQL} {
+		if limit.Limit == 0 {
+			continue
+		}
+		usage := githubRateLimits.resources[resource]
+		usage.rateLimit = limit
+		githubRateLimits.resources[resource] = usage
+	}
+}
+
+func rateLimitSnapshot() map[string]rateLimitUsage {
+	githubRateLimits.mu.Lock()
+	defer githubRateLimits.mu.Unlock()
+	snapshot := make(map[string]rateLimitUsage, len(githubRateLimits.resources))
+	for resource, usage := range githubRateLimits.resources {
+		snapshot[resource] = usage
+	}
+	return snapshot
+}
+
+// rateLimitWindow is how often the limit of a resource resets.
+func rateLimitWindow(resource string) time.Duration {
+	if resource == "search" || resource == "code_search" {
+		return time.Minute
+	}
+	return time.Hour
+}
+
+// rateLimitWait is how long to wait for the reset when the answer with header
+// used up the last request of its rate limit.
+func rateLimitWait(header http.Header) time.Duration {
+	if header.Get("X-RateLimit-Remaining") != "0" {
+		return 0
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	wait := time.Until(time.Unix(reset, 0)) + time.Second
+	switch {
+	case wait < 0:
+		return 0
+	case wait > time.Hour:
+		// A clock far off from GitHub's, a window is never longer.
+		return time.Hour
+	}
+	return wait
+}
+
+// rateLimitBudget describes what is left of the rate limits spent so far,
+// such as "search 12/30 core 4870/5000".
+func rateLimitBudget() string {
+	snapshot := rateLimitSnapshot()
+	resources := make([]string, 0, len(snapshot))
+	for resource, usage := range snapshot {
+		if usage.requests > 0 {
+			resources = append(resources, resource)
+		}
+	}
+	sort.Strings(resources)
+	var parts []string
+	for _, resource := range resources {
+		usage := snapshot[resource]
+		parts = append(parts, fmt.Sprintf("%s %d/%d", resource, usage.Remaining, usage.Limit))
+	}
+	return strings.Join(parts, " ")
+}
+
+// rateLimitETA estimates how long the remaining employees wait for rate
+// limits to reset, assuming each costs what the done ones cost since
+// baseline. A resource whose budget covers them adds no wait.
+func rateLimitETA(baseline map[string]rateLimitUsage, done int, remaining int) time.Duration {
+	if done == 0 || remaining == 0 {
+		return 0
+	}
+	var longest time.Duration
+	for resource, usage := range rateLimitSnapshot() {
+		spent := usage.requests - baseline[resource].requests
+		if spent <= 0 || usage.Limit == 0 {
+			continue
+		}
+		short := float64(spent)/float64(done)*float64(remaining) - float64(usage.Remaining)
+		if short <= 0 {
+			continue
+		}
+		windows := math.Ceil(short / float64(usage.Limit))
+		wait := time.Until(time.Unix(usage.Reset, 0)) + time.Duration(windows-1)*rateLimitWindow(resource)
+		if wait > longest {
+			longest = wait
+		}
+	}
+	return longest
+}
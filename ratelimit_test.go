@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// withRateLimits starts a test from no known rate limits.
+func withRateLimits(t *testing.T) {
+	githubRateLimits.mu.Lock()
+	saved := githubRateLimits.resources
+	githubRateLimits.resources = make(map[string]rateLimitUsage)
+	githubRateLimits.mu.Unlock()
+	t.Cleanup(func() {
+		githubRateLimits.mu.Lock()
+		githubRateLimits.resources = saved
+		githubRateLimits.mu.Unlock()
+	})
+}
+
+func rateLimitHeader(resource string, limit int, remaining int, reset time.Time) http.Header {
+	header := http.Header{}
+	header.Set("X-RateLimit-Resource", resource)
+	header.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	header.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	return header
+}
+
+func TestRateLimitWait(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		header   http.Header
+		min, max time.Duration
+	}{
+		{name: "requests left", header: rateLimitHeader("search", 30, 1, now.Add(time.Minute))},
+		{name: "no headers", header: http.Header{}},
+		{name: "reset passed", header: rateLimitHeader("search", 30, 0, now.Add(-time.Minute))},
+		{name: "used up", header: rateLimitHeader("search", 30, 0, now.Add(30*time.Second)), min: 29 * time.Second, max: 32 * time.Second},
+		{name: "capped at an hour", header: rateLimitHeader("core", 5000, 0, now.Add(5*time.Hour)), min: time.Hour, max: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rateLimitWait(tt.header); got < tt.min || got > tt.max {
+				t.Errorf("rateLimitWait = %s, want between %s and %s", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestRecordRateLimit(t *testing.T) {
+	withRateLimits(t)
+	reset := time.Now().Add(time.Minute)
+	recordRateLimit(rateLimitHeader("search", 30, 29, reset))
+	recordRateLimit(rateLimitHeader("search", 30, 28, reset))
+	recordRateLimit(rateLimitHeader("core", 5000, 4999, reset))
+	// Answers without rate limit headers are not counted.
+	recordRateLimit(http.Header{})
+	noteTokenStatus(githubTokenStatus{GraphQL: rateLimit{Limit: 5000, Remaining: 5000}})
+
+	snapshot := rateLimitSnapshot()
+	if got := snapshot["search"]; got.requests != 2 || got.Remaining != 28 || got.Limit != 30 || got.Reset != reset.Unix() {
+		t.Errorf("search = %+v", got)
+	}
+	if got := snapshot["graphql"]; got.requests != 0 || got.Remaining != 5000 {
+		t.Errorf("graphql = %+v", got)
+	}
+	// Resources only known from /rate_limit are left out of the budget.
+	if got, want := rateLimitBudget(), "core 4999/5000 search 28/30"; got != want {
+		t.Errorf("rateLimitBudget = %q, want %q", got, want)
+	}
+}
+
+func TestRateLimitETA(t *testing.T) {
+	withRateLimits(t)
+	reset := time.Now().Add(40 * time.Second)
+	baseline := rateLimitSnapshot()
+	// 10 employees spent 20 searches, leaving 10 of 30.
+	for remaining := 29; remaining >= 10; remaining-- {
+		recordRateLimit(rateLimitHeader("search", 30, remaining, reset))
+	}
+
+	tests := []struct {
+		name      string
+		done      int
+		remaining int
+		min, max  time.Duration
+	}{
+		{name: "nothing done", done: 0, remaining: 10},
+		{name: "nothing left", done: 10, remaining: 0},
+		{name: "budget covers the rest", done: 10, remaining: 5},
+		// 40 searches needed, 10 left: one reset covers 30 more.
+		{name: "waits for the reset", done: 10, remaining: 20, min: 38 * time.Second, max: 41 * time.Second},
+		// 80 needed, 10 left: 70 more need three windows.
+		{name: "several windows", done: 10, remaining: 40, min: 2*time.Minute + 38*time.Second, max: 2*time.Minute + 41*time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rateLimitETA(baseline, tt.done, tt.remaining); got < tt.min || got > tt.max {
+				t.Errorf("rateLimitETA = %s, want between %s and %s", got, tt.min, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// matchesByEmployee groups matches under the employee they were found for,
+// keyed by employeeKey.
+func matchesByEmployee(matches []Match) map[string][]Match {
+	grouped := make(map[string][]Match)
+	for _, match := range matches {
+		key := employeeKey(match.Employee)
+		grouped[key] = append(grouped[key], match)
+	}
+	return grouped
+}
+
+// matchedEmployees lists the employees with at least one match, in the order
+// of the employee list. Matches may come from employees missing in the list,
+// they are listed too.
+func matchedEmployees(results Results, grouped map[string][]Match) []Employee {
+	var matched []Employee
+	seen := make(map[string]bool)
+	employees := results.Employees
+	for _, match := range results.Matches {
+		employees = append(employees, match.Employee)
+	}
+	for _, employee := range employees {
+		key := employeeKey(employee)
+		if seen[key] || len(grouped[key]) == 0 {
+			continue
+		}
+		seen[key] = true
+		matched = append(matched, employee)
+	}
+	return matched
+}
+
+// printReport prints a summary of results followed by every employee with a
+// match, their GitHub accounts and the evidence for each.
+func printReport(results Results) {
+	grouped := matchesByEmployee(results.Matches)
+
+	color.Cyan("[+] Run started %s, mode %s", results.StartedAt.Format("2006-01-02 15:04"), results.Mode)
+	if len(results.Keywords) > 0 {
+		color.Cyan("[+] Keywords: %s", strings.Join(results.Keywords, ", "))
+	}
+	color.Cyan("[+] %d employees, %d with GitHub accounts, %d accounts in total", len(results.Employees), len(grouped), len(results.Matches))
+	if len(results.Discarded) > 0 {
+		color.Cyan("[+] %d matches dropped as their login was assigned to another employee", len(results.Discarded))
+	}
+	fmt.Println()
+
+	for _, employee := range matchedEmployees(results, grouped) {
+		key := employeeKey(employee)
+		details := []string{}
+		for _, detail := range []string{employee.Title, employee.Location, employee.Status} {
+			if detail != "" {
+				details = append(details, detail)
+			}
+		}
+		if len(details) > 0 {
+			fmt.Printf("%s (%s)\n", employee.Name, strings.Join(details, ", "))
+		} else {
+			fmt.Println(employee.Name)
+		}
+
+		for _, match := range grouped[key] {
+			switch match.Decision {
+			case "":
+				color.Green("    [*] %s %s (score %g)", match.Login, match.ProfileURL, match.Score)
+			case decisionRejected:
+				color.Red("    [-] %s %s (score %g, rejected)", match.Login, match.ProfileURL, match.Score)
+			default:
+				color.Green("    [*] %s %s (score %g, %s)", match.Login, match.ProfileURL, match.Score, match.Decision)
+			}
+			if match.Ambiguous {
+				color.Yellow("        ambiguous, also found for %s", strings.Join(match.Contenders, ", "))
+			} else if len(match.Contenders) > 0 {
+				fmt.Printf("        also found for %s\n", strings.Join(match.Contenders, ", "))
+			}
+			for _, evidence := range match.Evidence {
+				fmt.Printf("        %s: %s %s\n", evidence.Kind, evidence.Detail, evidence.URL)
+			}
+		}
+	}
+}
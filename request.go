@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const linkedInBaseURL = "https://www.linkedin.com"
+
+// capturedRequest is the LinkedIn search request the user captured in their
+// browser, in whichever format it was exported.
+type capturedRequest struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   string
+
+	// ctx, when set, cancels the requests of the search.
+	ctx context.Context
+	// progress counts the pages fetched.
+	progress *progress
+	// firstPage is the first page of the search, already fetched by
+	// checkLinkedInSession, so it is not asked for twice.
+	firstPage *linkedInPage
+}
+
+// ignoredHeaders are managed by net/http and must not be copied from the
+// capture. Accept-Encoding in particular would stop the transport from
+// transparently decompressing the response.
+var ignoredHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Accept-Encoding":   true,
+	"Connection":        true,
+	"Transfer-Encoding": true,
+}
+
+// voyagerURLReplacer decodes the characters voyager uses to structure its
+// query variables, so they can be rewritten, while leaving encoded spaces and
+// the like untouched.
+var voyagerURLReplacer = strings.NewReplacer(
+	"%28", "(", "%29", ")", "%3A", ":", "%3a", ":", "%2C", ",", "%2c", ",",
+)
+
+func loadCapturedRequest(filename string) (capturedRequest, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return capturedRequest{}, err
+	}
+	captured, err := parseCapturedRequest(data)
+	if err != nil {
+		return capturedRequest{}, fmt.Errorf("%s: %v", filename, err)
+	}
+	return captured, nil
+}
+
+// parseCapturedRequest detects whether data is a HAR export, a "Copy as cURL"
+// command, a Burp saved request (XML or raw) or a plain header dump, and
+// extracts the request from it.
+func parseCapturedRequest(data []byte) (capturedRequest, error) {
+	text := strings.TrimSpace(strings.TrimPrefix(string(data), "\ufeff"))
+	if text == "" {
+		return capturedRequest{}, errors.New("request file is empty")
+	}
+
+	var captured capturedRequest
+	var err error
+	switch {
+	case strings.HasPrefix(text, "{"):
+		captured, err = parseHAR([]byte(text))
+	case strings.HasPrefix(text, "curl ") || strings.HasPrefix(text, "curl.exe "):
+		captured, err = parseCurl(text)
+	case strings.HasPrefix(text, "<?xml") || strings.HasPrefix(text, "<items"):
+		captured, err = parseBurpXML([]byte(text))
+	default:
+		captured, err = parseRawRequest(text, "")
+	}
+	if err != nil {
+		return capturedRequest{}, err
+	}
+
+	if captured.Method == "" {
+		captured.Method = http.MethodGet
+	}
+	if captured.URL == "" {
+		return capturedRequest{}, errors.New("no request URL found")
+	}
+	captured.URL = voyagerURLReplacer.Replace(captured.URL)
+	return captured, nil
+}
+
+func newCapturedRequest() capturedRequest {
+	return capturedRequest{Header: make(http.Header)}
+}
+
+func (c *capturedRequest) addHeader(name string, value string) {
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+	if name == "" || strings.HasPrefix(name, ":") || ignoredHeaders[http.CanonicalHeaderKey(name)] {
+		return
+	}
+	c.Header.Add(name, value)
+}
+
+// addCookies merges name=value pairs into the Cookie header.
+func (c *capturedRequest) addCookies(cookies string) {
+	cookies = strings.TrimSpace(cookies)
+	if cookies == "" {
+		return
+	}
+	if existing := c.Header.Get("Cookie"); existing != "" {
+		cookies = existing + "; " + cookies
+	}
+	c.Header.Set("Cookie", cookies)
+}
+
+// cookie returns the value of the named cookie sent with the request.
+func (c capturedRequest) cookie(name string) string {
+	req := http.Request{Header: c.Header}
+	cookie, err := req.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(cookie.Value, `"`)
+}
+
+var startVariablePattern = regexp.MustCompile("start:[^,)]+")
+
+// newRequest builds the request for the page of results beginning at start,
+// narrowed down to slice.
+func (c capturedRequest) newRequest(start int, slice searchSlice) (*http.Request, error) {
+	requestURL := startVariablePattern.ReplaceAllString(c.URL, "start:"+strconv.Itoa(start))
+	requestURL = slice.apply(requestURL)
+
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, c.Method, requestURL, strings.NewReader(c.Body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.Header {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	return req, nil
+}
+
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				Cookies []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"cookies"`
+				PostData struct {
+					Text string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// parseHAR picks the voyager search request out of a HAR export, falling back
+// to any voyager request and then to the first entry.
+func parseHAR(data []byte) (capturedRequest, error) {
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return capturedRequest{}, fmt.Errorf("invalid HAR file: %v", err)
+	}
+	entries := har.Log.Entries
+	if len(entries) == 0 {
+		return capturedRequest{}, errors.New("HAR file has no entries")
+	}
+
+	chosen := -1
+	for i, entry := range entries {
+		if strings.Contains(entry.Request.URL, "/voyager/api/graphql") && strings.Contains(entry.Request.URL, "SEARCH") {
+			chosen = i
+			break
+		}
+	}
+	if chosen < 0 {
+		for i, entry := range entries {
+			if strings.Contains(entry.Request.URL, "/voyager/api/") {
+				chosen = i
+				break
+			}
+		}
+	}
+	if chosen < 0 {
+		chosen = 0
+	}
+
+	request := entries[chosen].Request
+	captured := newCapturedRequest()
+	captured.Method = request.Method
+	captured.URL = request.URL
+	captured.Body = request.PostData.Text
+	for _, header := range request.Headers {
+		captured.addHeader(header.Name, header.Value)
+	}
+	if captured.Header.Get("Cookie") == "" {
+		var pairs []string
+		for _, cookie := range request.Cookies {
+			pairs = append(pairs, cookie.Name+"="+cookie.Value)
+		}
+		captured.addCookies(strings.Join(pairs, "; "))
+	}
+	return captured, nil
+}
+
+var cmdCaretPattern = regexp.MustCompile(`\^(.)`)
+
+// parseCurl understands the commands produced by "Copy as cURL" in Chrome,
+// Firefox and Burp, for both bash and cmd quoting.
+func parseCurl(command string) (capturedRequest, error) {
+	command = strings.NewReplacer("\\\r\n", " ", "\\\n", " ", "^\r\n", " ", "^\n", " ").Replace(command)
+	if strings.Contains(command, `^"`) {
+		// cmd escapes every special character with a caret.
+		command = cmdCaretPattern.ReplaceAllString(command, "$1")
+	}
+	args, err := splitShellWords(command)
+	if err != nil {
+		return capturedRequest{}, err
+	}
+
+	captured := newCapturedRequest()
+	hasData := false
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		next := func() string {
+			if i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return ""
+		}
+		switch {
+		case arg == "-X" || arg == "--request":
+			captured.Method = strings.ToUpper(next())
+		case arg == "-H" || arg == "--header":
+			parts := strings.SplitN(next(), ":", 2)
+			if len(parts) == 2 {
+				captured.addHeader(parts[0], parts[1])
+			}
+		case arg == "-b" || arg == "--cookie":
+			captured.addCookies(next())
+		case arg == "-d" || arg == "--data" || arg == "--data-raw" || arg == "--data-binary" || arg == "--data-ascii":
+			captured.Body = next()
+			hasData = true
+		case arg == "--url":
+			captured.URL = next()
+		case arg == "-A" || arg == "--user-agent":
+			captured.Header.Set("User-Agent", next())
+		case arg == "-e" || arg == "--referer":
+			captured.Header.Set("Referer", next())
+		case arg == "-x" || arg == "--proxy" || arg == "-o" || arg == "--output" || arg == "-u" || arg == "--user":
+			next()
+		case strings.HasPrefix(arg, "-"):
+			// Flags without a value, such as --compressed or -k.
+		case captured.URL == "":
+			captured.URL = arg
+		}
+	}
+	if captured.Method == "" && hasData {
+		captured.Method = http.MethodPost
+	}
+	return captured, nil
+}
+
+// splitShellWords splits a command line the way a POSIX shell would,
+// including $'...' strings.
+func splitShellWords(line string) ([]string, error) {
+	var words []string
+	var current strings.Builder
+	inWord := false
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '\'':
+			inWord = true
+			i += 2
+			for ; i < len(runes) && runes[i] != '\''; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						current.WriteRune('\n')
+					case 't':
+						current.WriteRune('\t')
+					case 'r':
+						current.WriteRune('\r')
+					default:
+						current.WriteRune(runes[i])
+					}
+					continue
+				}
+				current.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, errors.New("unterminated $' string in curl command")
+			}
+		case r == '\'':
+			inWord = true
+			i++
+			for ; i < len(runes) && runes[i] != '\''; i++ {
+				current.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, errors.New("unterminated ' string in curl command")
+			}
+		case r == '"':
+			inWord = true
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune(`"\\$`+"`", runes[i+1]) {
+					i++
+				}
+				current.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, errors.New("unterminated \" string in curl command")
+			}
+		case r == '\\' && i+1 < len(runes):
+			inWord = true
+			i++
+			current.WriteRune(runes[i])
+		default:
+			inWord = true
+			current.WriteRune(r)
+		}
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
+
+type burpItems struct {
+	Items []struct {
+		URL     string `xml:"url"`
+		Host    string `xml:"host"`
+		Request struct {
+			Base64 bool   `xml:"base64,attr"`
+			Text   string `xml:",chardata"`
+		} `xml:"request"`
+	} `xml:"item"`
+}
+
+// parseBurpXML reads the first item of a Burp "Save items" export.
+func parseBurpXML(data []byte) (capturedRequest, error) {
+	var items burpItems
+	if err := xml.Unmarshal(data, &items); err != nil {
+		return capturedRequest{}, fmt.Errorf("invalid Burp XML file: %v", err)
+	}
+	if len(items.Items) == 0 {
+		return capturedRequest{}, errors.New("Burp XML file has no items")
+	}
+
+	item := items.Items[0]
+	raw := item.Request.Text
+	if item.Request.Base64 {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(raw))
+		if err != nil {
+			return capturedRequest{}, fmt.Errorf("invalid base64 request in Burp XML file: %v", err)
+		}
+		raw = string(decoded)
+	}
+	return parseRawRequest(raw, item.URL)
+}
+
+// parseRawRequest reads an HTTP/1.x request as saved by Burp or copied from the
+// browser, or a bare header dump with HTTP/2 pseudo headers such as Chrome's
+// "Copy request headers". knownURL, when set, wins over the request line.
+func parseRawRequest(raw string, knownURL string) (capturedRequest, error) {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	head, body := raw, ""
+	if parts := strings.SplitN(raw, "\n\n", 2); len(parts) == 2 {
+		head, body = parts[0], parts[1]
+	}
+
+	captured := newCapturedRequest()
+	captured.Body = strings.TrimRight(body, "\n")
+	var host, path, scheme string
+
+	scanner := bufio.NewScanner(strings.NewReader(head))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if first {
+			first = false
+			if fields := strings.Fields(line); len(fields) >= 2 && !strings.Contains(fields[0], ":") {
+				captured.Method = fields[0]
+				path = fields[1]
+				continue
+			}
+		}
+
+		// Pseudo headers start with a colon, so split after it.
+		sep := strings.Index(line[1:], ":") + 1
+		if sep <= 0 {
+			return capturedRequest{}, fmt.Errorf("malformed header line %q", line)
+		}
+		name, value := strings.TrimSpace(line[:sep]), strings.TrimSpace(line[sep+1:])
+		switch strings.ToLower(name) {
+		case ":method":
+			captured.Method = value
+		case ":path":
+			path = value
+		case ":authority", "host":
+			host = value
+		case ":scheme":
+			scheme = value
+		case "cookie":
+			captured.addCookies(value)
+		default:
+			captured.addHeader(name, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return capturedRequest{}, err
+	}
+
+	switch {
+	case knownURL != "":
+		captured.URL = knownURL
+	case strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://"):
+		captured.URL = path
+	case path == "":
+		return capturedRequest{}, errors.New("no request line or :path header found")
+	case host == "":
+		captured.URL = linkedInBaseURL + path
+	default:
+		if scheme == "" {
+			scheme = "https"
+		}
+		captured.URL = (&url.URL{Scheme: scheme, Host: host}).String() + path
+	}
+	return captured, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+const testSearchURL = "https://www.linkedin.com/voyager/api/graphql?variables=(start:0,query:(flagshipSearchIntent:SEARCH_SRP))&queryId=voyagerSearchDashClusters.abc"
+
+func TestParseCapturedRequest(t *testing.T) {
+	burpRaw := "GET /voyager/api/graphql?variables=(start:0,query:(flagshipSearchIntent:SEARCH_SRP))&queryId=voyagerSearchDashClusters.abc HTTP/2\r\n" +
+		"Host: www.linkedin.com\r\n" +
+		"Cookie: li_at=secret; JSESSIONID=\"ajax:123\"\r\n" +
+		"Csrf-Token: ajax:123\r\n" +
+		"Accept-Encoding: gzip\r\n\r\n"
+
+	tests := []struct {
+		name       string
+		data       string
+		wantMethod string
+		wantURL    string
+		wantHeader map[string]string
+		wantBody   string
+		wantErr    bool
+	}{
+		{
+			name: "HAR picks the search entry",
+			data: `{"log":{"entries":[
+				{"request":{"method":"GET","url":"https://www.linkedin.com/voyager/api/me","headers":[]}},
+				{"request":{"method":"GET","url":"https://www.linkedin.com/voyager/api/graphql?variables=(start:0,query:(flagshipSearchIntent:SEARCH_SRP))&queryId=voyagerSearchDashClusters.abc",
+					"headers":[{"name":":authority","value":"www.linkedin.com"},{"name":"csrf-token","value":"ajax:123"},{"name":"accept-encoding","value":"gzip"}],
+					"cookies":[{"name":"li_at","value":"secret"},{"name":"JSESSIONID","value":"\"ajax:123\""}]}}
+			]}}`,
+			wantMethod: "GET",
+			wantURL:    testSearchURL,
+			wantHeader: map[string]string{
+				"Csrf-Token":      "ajax:123",
+				"Cookie":          `li_at=secret; JSESSIONID="ajax:123"`,
+				"Accept-Encoding": "",
+			},
+		},
+		{
+			name: "bash curl",
+			data: `curl 'https://www.linkedin.com/voyager/api/graphql?variables=(start:0,query:(flagshipSearchIntent:SEARCH_SRP))&queryId=voyagerSearchDashClusters.abc' \
+  -H 'csrf-token: ajax:123' \
+  -b 'li_at=secret' \
+  --compressed`,
+			wantMethod: "GET",
+			wantURL:    testSearchURL,
+			wantHeader: map[string]string{
+				"Csrf-Token": "ajax:123",
+				"Cookie":     "li_at=secret",
+			},
+		},
+		{
+			name:       "cmd curl with data",
+			data:       `curl ^"https://www.linkedin.com/voyager/api/graphql?variables=^(start:0,query:^(flagshipSearchIntent:SEARCH_SRP^)^)^&queryId=voyagerSearchDashClusters.abc^" -H ^"csrf-token: ajax:123^" --data-raw ^"a=b^"`,
+			wantMethod: "POST",
+			wantURL:    testSearchURL,
+			wantHeader: map[string]string{"Csrf-Token": "ajax:123"},
+			wantBody:   "a=b",
+		},
+		{
+			name:       "curl with encoded variables",
+			data:       `curl $'https://www.linkedin.com/voyager/api/graphql?variables=%28start%3A0%2Cquery%3A%28flagshipSearchIntent%3ASEARCH_SRP%29%29&queryId=voyagerSearchDashClusters.abc'`,
+			wantMethod: "GET",
+			wantURL:    testSearchURL,
+		},
+		{
+			name: "Burp XML base64",
+			data: `<?xml version="1.0"?><items><item><url><![CDATA[` + testSearchURL + `]]></url>` +
+				`<request base64="true"><![CDATA[` + base64.StdEncoding.EncodeToString([]byte(burpRaw)) + `]]></request></item></items>`,
+			wantMethod: "GET",
+			wantURL:    testSearchURL,
+			wantHeader: map[string]string{
+				"Csrf-Token": "ajax:123",
+				"Cookie":     `li_at=secret; JSESSIONID="ajax:123"`,
+			},
+		},
+		{
+			name:       "raw Burp request",
+			data:       burpRaw,
+			wantMethod: "GET",
+			wantURL:    testSearchURL,
+			wantHeader: map[string]string{
+				"Csrf-Token":      "ajax:123",
+				"Host":            "",
+				"Accept-Encoding": "",
+			},
+		},
+		{
+			name: "header dump with pseudo headers",
+			data: ":method: GET\n" +
+				":authority: www.linkedin.com\n" +
+				":scheme: https\n" +
+				":path: /voyager/api/graphql?variables=(start:0,query:(flagshipSearchIntent:SEARCH_SRP))&queryId=voyagerSearchDashClusters.abc\n" +
+				"csrf-token: ajax:123\n",
+			wantMethod: "GET",
+			wantURL:    testSearchURL,
+			wantHeader: map[string]string{"Csrf-Token": "ajax:123"},
+		},
+		{
+			name:    "empty file",
+			data:    " \n",
+			wantErr: true,
+		},
+		{
+			name:    "HAR without entries",
+			data:    `{"log":{"entries":[]}}`,
+			wantErr: true,
+		},
+		{
+			name:    "raw request without a path",
+			data:    "csrf-token: ajax:123\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCapturedRequest([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCapturedRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Method != tt.wantMethod {
+				t.Errorf("Method = %q, want %q", got.Method, tt.wantMethod)
+			}
+			if got.URL != tt.wantURL {
+				t.Errorf("URL = %q, want %q", got.URL, tt.wantURL)
+			}
+			for name, want := range tt.wantHeader {
+				if value := got.Header.Get(name); value != want {
+					t.Errorf("header %s = %q, want %q", name, value, want)
+				}
+			}
+			if got.Body != tt.wantBody {
+				t.Errorf("Body = %q, want %q", got.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{line: `curl -H 'a: b'  "c d"`, want: []string{"curl", "-H", "a: b", "c d"}},
+		{line: `curl $'x\ty' "a\"b" c\ d`, want: []string{"curl", "x\ty", `a"b`, "c d"}},
+		{line: `curl 'unterminated`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := splitShellWords(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("splitShellWords(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitShellWords(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestCapturedRequestNewRequest(t *testing.T) {
+	captured, err := parseCapturedRequest([]byte("curl '" + testSearchURL + "' -H 'csrf-token: ajax:123'"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := captured.newRequest(20, searchSlice{}.with("geoUrn", "1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "https://www.linkedin.com/voyager/api/graphql?variables=(start:20,query:(queryParameters:List((key:geoUrn,value:List(1))),flagshipSearchIntent:SEARCH_SRP))&queryId=voyagerSearchDashClusters.abc"
+	if got := req.URL.String(); got != want {
+		t.Errorf("URL = %q, want %q", got, want)
+	}
+	if got := req.Header.Get("Csrf-Token"); got != "ajax:123" {
+		t.Errorf("Csrf-Token = %q", got)
+	}
+	if got := captured.cookie("JSESSIONID"); got != "" {
+		t.Errorf("cookie(JSESSIONID) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Review decisions on a match.
+const (
+	decisionConfirmed = "confirmed"
+	decisionRejected  = "rejected"
+	decisionUnsure    = "unsure"
+)
+
+const reviewPrompt = "[c]onfirm, [r]eject, [u]nsure, [s]kip, [o]pen profile, [b]ack, [q]uit > "
+
+func runReview(name string, args []string) error {
+	fs := newFlagSet(name, "Walks through the matches of a results file, or of a run in the store, to confirm, reject or mark them unsure. Every decision is saved back right away.")
+	resultsFile := fs.String("results", "", "path of the results file")
+	database := fs.String("db", "", "path of the SQLite store, to review one of its runs instead of a results file")
+	engagement := fs.String("engagement", "", "engagement whose latest run is reviewed with -db")
+	runID := fs.Int64("run", 0, "ID of the run to review with -db, defaults to the latest run of -engagement")
+	all := fs.Bool("all", false, "also show matches that already have a decision")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if (*resultsFile == "") == (*database == "") {
+		return usageError(fs, "Give either -results or -db")
+	}
+
+	var results Results
+	var save func(match Match) error
+	if *database != "" {
+		db, err := openStore(*database)
+		if err != nil {
+			return err
+		}
+		defer db.close()
+		id := *runID
+		if id == 0 {
+			if id, err = db.latestRun(*engagement); err != nil {
+				return err
+			}
+		}
+		if results, err = db.loadRun(id); err != nil {
+			return err
+		}
+		color.Cyan("[+] Reviewing run %d", id)
+		save = func(match Match) error {
+			return db.setDecision(id, match)
+		}
+	} else {
+		var err error
+		if results, err = loadResults(*resultsFile); err != nil {
+			return fmt.Errorf("can not read results: %v", err)
+		}
+		save = func(Match) error {
+			return saveResults(*resultsFile, results)
+		}
+	}
+
+	queue := reviewQueue(results, *all)
+	if len(queue) == 0 {
+		color.Green("[*] Nothing left to review")
+		return nil
+	}
+	color.Cyan("[+] %d matches to review", len(queue))
+
+	input := bufio.NewReader(os.Stdin)
+	for position := 0; position < len(queue); {
+		match := &results.Matches[queue[position]]
+		fmt.Println()
+		printReviewMatch(*match, position+1, len(queue))
+
+		fmt.Print(reviewPrompt)
+		line, err := input.ReadString('\n')
+		if err != nil && line == "" {
+			if err == io.EOF {
+				fmt.Println()
+				break
+			}
+			return err
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "c", "confirm":
+			match.Decision = decisionConfirmed
+		case "r", "reject":
+			match.Decision = decisionRejected
+		case "u", "unsure":
+			match.Decision = decisionUnsure
+		case "s", "skip":
+			position++
+			continue
+		case "":
+			// A stray Enter must not skip the match unseen.
+			continue
+		case "o", "open":
+			if err := openBrowser(match.ProfileURL); err != nil {
+				color.Red("[-] Can not open the browser: %v", err)
+			}
+			continue
+		case "b", "back":
+			if position > 0 {
+				position--
+			}
+			continue
+		case "q", "quit":
+			position = len(queue)
+			continue
+		default:
+			color.Red("[-] Unknown key")
+			continue
+		}
+
+		if err := save(*match); err != nil {
+			return fmt.Errorf("can not save the decision: %v", err)
+		}
+		position++
+	}
+
+	printReviewSummary(results)
+	return nil
+}
+
+// reviewQueue lists the indexes of the matches to review, grouped by
+// employee so the candidates of one person are seen one after another.
+func reviewQueue(results Results, all bool) []int {
+	var queue []int
+	seen := make(map[string]bool)
+	for i, match := range results.Matches {
+		key := employeeKey(match.Employee)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		for j := i; j < len(results.Matches); j++ {
+			candidate := results.Matches[j]
+			if employeeKey(candidate.Employee) == key && (all || candidate.Decision == "") {
+				queue = append(queue, j)
+			}
+		}
+	}
+	return queue
+}
+
+// reviewColumnWidth is the width of the LinkedIn column, printed beside the
+// GitHub candidate.
+const reviewColumnWidth = 40
+
+func printReviewMatch(match Match, position int, total int) {
+	employee := match.Employee
+	color.Cyan("[%d/%d] %s", position, total, employee.Name)
+
+	left := []string{"LinkedIn"}
+	for _, detail := range [][2]string{
+		{"Title", employee.Title},
+		{"Location", employee.Location},
+		{"Status", employee.Status},
+		{"Email", employee.Email},
+		{"Profile", employee.ProfileURL},
+	} {
+		if detail[1] != "" {
+			left = append(left, fmt.Sprintf("%-9s %s", detail[0]+":", detail[1]))
+		}
+	}
+
+	right := []string{fmt.Sprintf("GitHub %s (score %g)", match.Login, match.Score)}
+	for _, detail := range [][2]string{
+		{"Name", match.Name},
+		{"Location", match.Location},
+		{"Profile", match.ProfileURL},
+		{"Decision", match.Decision},
+	} {
+		if detail[1] != "" {
+			right = append(right, fmt.Sprintf("%-9s %s", detail[0]+":", detail[1]))
+		}
+	}
+	for _, evidence := range match.Evidence {
+		right = append(right, strings.TrimSpace(fmt.Sprintf("%s: %s %s", evidence.Kind, evidence.Detail, evidence.URL)))
+	}
+	if len(match.Contenders) > 0 {
+		right = append(right, "also found for "+strings.Join(match.Contenders, ", "))
+	}
+
+	for _, line := range sideBySide(left, right, reviewColumnWidth) {
+		fmt.Println("    " + line)
+	}
+	if match.Ambiguous {
+		color.Yellow("    [!] ambiguous, another employee scored as high for %s", match.Login)
+	}
+}
+
+// sideBySide lays left and right out as two columns, cutting the lines of
+// the left one to width.
+func sideBySide(left []string, right []string, width int) []string {
+	var lines []string
+	for i := 0; i < len(left) || i < len(right); i++ {
+		var l, r string
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		if cells := []rune(l); len(cells) > width {
+			l = string(cells[:width-3]) + "..."
+		}
+		lines = append(lines, strings.TrimRight(fmt.Sprintf("%-*s | %s", width, l, r), " "))
+	}
+	return lines
+}
+
+func printReviewSummary(results Results) {
+	counts := make(map[string]int)
+	for _, match := range results.Matches {
+		counts[match.Decision]++
+	}
+	color.Cyan("[+] %d confirmed, %d rejected, %d unsure, %d not reviewed",
+		counts[decisionConfirmed], counts[decisionRejected], counts[decisionUnsure], counts[""])
+}
+
+func valueOr(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func openBrowser(url string) error {
+	if url == "" {
+		return errors.New("the match has no profile URL")
+	}
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReviewQueue(t *testing.T) {
+	alice := Employee{Name: "Alice"}
+	bob := Employee{Name: "Bob"}
+	results := Results{Matches: []Match{
+		{Employee: alice, Login: "a1"},
+		{Employee: bob, Login: "b1", Decision: decisionConfirmed},
+		{Employee: alice, Login: "a2"},
+		{Employee: bob, Login: "b2"},
+		{Employee: alice, Login: "a3", Decision: decisionRejected},
+	}}
+
+	tests := []struct {
+		name string
+		all  bool
+		want []int
+	}{
+		{name: "undecided grouped by employee", want: []int{0, 2, 3}},
+		{name: "all", all: true, want: []int{0, 2, 4, 1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reviewQueue(results, tt.all); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reviewQueue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// withStdin feeds input to code reading os.Stdin for the duration of a test.
+func withStdin(t *testing.T, input string) {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(filename, []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdin
+	os.Stdin = f
+	t.Cleanup(func() {
+		os.Stdin = saved
+		f.Close()
+	})
+}
+
+func TestRunReview(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "decide every match", input: "c\nr\nu\n", want: []string{decisionConfirmed, decisionRejected, decisionUnsure}},
+		{name: "skip and go back", input: "s\nb\nr\ns\nc\n", want: []string{decisionRejected, "", decisionConfirmed}},
+		{name: "empty lines are asked again", input: "\nc\n\n\nr\nq\n", want: []string{decisionConfirmed, decisionRejected, ""}},
+		{name: "unknown keys are asked again", input: "x\nc\nq\n", want: []string{decisionConfirmed, "", ""}},
+		{name: "end of input", input: "r", want: []string{decisionRejected, "", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "results.json")
+			results := Results{Matches: []Match{
+				{Employee: Employee{Name: "Alice"}, Login: "a1"},
+				{Employee: Employee{Name: "Alice"}, Login: "a2"},
+				{Employee: Employee{Name: "Bob"}, Login: "b1"},
+			}}
+			if err := saveResults(filename, results); err != nil {
+				t.Fatal(err)
+			}
+			withStdin(t, tt.input)
+
+			if err := runReview("mulef review", []string{"-results", filename}); err != nil {
+				t.Fatal(err)
+			}
+			saved, err := loadResults(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, match := range saved.Matches {
+				got = append(got, match.Decision)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decisions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunReviewStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mulef.db")
+	db, err := openStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.close()
+	alice := Employee{Name: "Alice", Location: "Berlin"}
+	bob := Employee{URN: "urn:li:member:2", Name: "Bob"}
+	older, err := db.saveRun(Results{Engagement: "acme", Matches: []Match{{Employee: alice, Login: "a1"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err := db.saveRun(Results{
+		Engagement: "acme",
+		Employees:  []Employee{alice, bob},
+		Matches:    []Match{{Employee: alice, Login: "a1"}, {Employee: bob, Login: "b1"}},
+		// The same login lost for Alice keeps its own row.
+		Discarded: []Match{{Employee: alice, Login: "b1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withStdin(t, "c\nr\n")
+
+	if err := runReview("mulef review", []string{"-db", path, "-engagement", "acme"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		run  int64
+		want []string
+	}{
+		{run: latest, want: []string{decisionConfirmed, decisionRejected}},
+		{run: older, want: []string{""}},
+	}
+	for _, tt := range tests {
+		results, err := db.loadRun(tt.run)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, match := range results.Matches {
+			got = append(got, match.Decision)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("run %d decisions = %q, want %q", tt.run, got, tt.want)
+		}
+		for _, match := range results.Discarded {
+			if match.Decision != "" {
+				t.Errorf("run %d discarded %s got decision %q", tt.run, match.Login, match.Decision)
+			}
+		}
+	}
+}
+
+func TestRunReviewFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"-results", "results.json", "-db", "mulef.db"},
+	} {
+		if err := runReview("mulef review", args); err == nil {
+			t.Errorf("runReview(%q) succeeded, want a usage error", args)
+		}
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	tests := []struct {
+		name        string
+		left, right []string
+		want        []string
+	}{
+		{
+			name:  "right column longer",
+			left:  []string{"LinkedIn"},
+			right: []string{"GitHub a1", "Name: A"},
+			want:  []string{"LinkedIn    | GitHub a1", "            | Name: A"},
+		},
+		{
+			name:  "left column cut",
+			left:  []string{"Location: Berlin, Germany", "x"},
+			right: []string{"GitHub a1"},
+			want:  []string{"Location... | GitHub a1", "x           |"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sideBySide(tt.left, tt.right, 11); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sideBySide() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rosterColumns maps the CSV headers accepted for each Employee field, in
+// lower case with spaces, dashes and underscores removed.
+var rosterColumns = map[string][]string{
+	"urn":        {"urn", "linkedinurn", "id"},
+	"name":       {"name", "fullname", "employee", "employeename", "displayname"},
+	"firstname":  {"firstname", "givenname"},
+	"lastname":   {"lastname", "surname", "familyname"},
+	"location":   {"location", "city", "office", "country"},
+	"title":      {"title", "jobtitle", "position", "role", "headline"},
+	"email":      {"email", "emailaddress", "mail", "workemail"},
+	"status":     {"status", "employment"},
+	"profileurl": {"profileurl", "linkedin", "linkedinurl", "url"},
+}
+
+// rosterHeader is the header of the CSV files written by saveEmployeeList.
+var rosterHeader = []string{"urn", "name", "location", "title", "email", "status", "profile_url"}
+
+// loadEmployees reads an employee roster from a JSON or CSV file, so the
+// GitHub matching can run on employees that did not come from LinkedIn.
+func loadEmployees(filename string) ([]Employee, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var employees []Employee
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if strings.EqualFold(filepath.Ext(filename), ".json") || bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")) {
+		employees, err = parseEmployeesJSON(trimmed)
+	} else {
+		employees, err = parseEmployeesCSV(trimmed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if len(employees) == 0 {
+		return nil, fmt.Errorf("%s: no employees found", filename)
+	}
+	return employees, nil
+}
+
+// parseEmployeesJSON accepts either a list of employees or an object with an
+// "employees" list.
+func parseEmployeesJSON(data []byte) ([]Employee, error) {
+	var employees []Employee
+	if bytes.HasPrefix(data, []byte("{")) {
+		var wrapped Employees
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return nil, err
+		}
+		employees = wrapped.Employees
+	} else if err := json.Unmarshal(data, &employees); err != nil {
+		return nil, err
+	}
+
+	var named []Employee
+	for _, employee := range employees {
+		if employee.Name = strings.TrimSpace(employee.Name); employee.Name != "" {
+			named = append(named, employee)
+		}
+	}
+	return named, nil
+}
+
+// parseEmployeesCSV reads a CSV file with a header row. A name column, or
+// first and last name columns, is required; every other column is optional.
+func parseEmployeesCSV(data []byte) ([]Employee, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	// Spreadsheets in many locales export with semicolons.
+	firstLine := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		firstLine = data[:i]
+	}
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.NewReplacer(" ", "", "_", "", "-", "").Replace(strings.ToLower(strings.TrimSpace(name)))
+		for field, aliases := range rosterColumns {
+			if _, taken := columns[field]; taken {
+				continue
+			}
+			for _, alias := range aliases {
+				if name == alias {
+					columns[field] = i
+				}
+			}
+		}
+	}
+	_, hasName := columns["name"]
+	_, hasFirstName := columns["firstname"]
+	if !hasName && !hasFirstName {
+		return nil, errors.New("no name column in CSV header, expected name or first name and last name")
+	}
+
+	var employees []Employee
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		employee := Employee{
+			URN:        field("urn"),
+			Name:       field("name"),
+			Location:   field("location"),
+			Title:      field("title"),
+			Email:      field("email"),
+			Status:     strings.ToLower(field("status")),
+			ProfileURL: field("profileurl"),
+		}
+		if employee.Name == "" {
+			employee.Name = strings.TrimSpace(field("firstname") + " " + field("lastname"))
+		}
+		if employee.Name != "" {
+			employees = append(employees, employee)
+		}
+	}
+	return employees, nil
+}
+
+// uniqueEmployees drops the employees listed more than once, such as people
+// returned on two pages of a search that shifted while it was paged through.
+func uniqueEmployees(employees []Employee) []Employee {
+	seen := make(map[string]bool)
+	var unique []Employee
+	for _, employee := range employees {
+		key := employeeKey(employee)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, employee)
+	}
+	return unique
+}
+
+// saveEmployeeList writes employees to filename as CSV when it ends in .csv
+// and as JSON otherwise, each employee once. Both formats can be read back
+// with -employees. It returns the number of employees written.
+func saveEmployeeList(filename string, employees []Employee) (int, error) {
+	employees = uniqueEmployees(employees)
+	f, err := os.Create(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if !strings.EqualFold(filepath.Ext(filename), ".csv") {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(Employees{Employees: employees}); err != nil {
+			return 0, err
+		}
+		return len(employees), f.Close()
+	}
+
+	writer := csv.NewWriter(f)
+	if err := writer.Write(rosterHeader); err != nil {
+		return 0, err
+	}
+	for _, employee := range employees {
+		record := []string{employee.URN, employee.Name, employee.Location, employee.Title, employee.Email, employee.Status, employee.ProfileURL}
+		if err := writer.Write(record); err != nil {
+			return 0, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return 0, err
+	}
+	return len(employees), f.Close()
+}
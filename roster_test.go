@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadEmployees(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		data     string
+		want     []Employee
+		wantErr  bool
+	}{
+		{
+			name:     "JSON list",
+			filename: "roster.json",
+			data:     `[{"name":" Alice ","location":"Berlin"},{"name":""}]`,
+			want:     []Employee{{Name: "Alice", Location: "Berlin"}},
+		},
+		{
+			name:     "JSON object without extension",
+			filename: "roster",
+			data:     `{"employees":[{"urn":"urn:li:member:1","name":"Alice","email":"alice@acme.com"}]}`,
+			want:     []Employee{{URN: "urn:li:member:1", Name: "Alice", Email: "alice@acme.com"}},
+		},
+		{
+			name:     "CSV with aliased headers",
+			filename: "roster.csv",
+			data:     "\xef\xbb\xbfFull Name,Job Title,City,E-Mail,Employment\nAlice Smith,Engineer,Berlin,alice@acme.com,Current\n,,,,\n",
+			want:     []Employee{{Name: "Alice Smith", Title: "Engineer", Location: "Berlin", Email: "alice@acme.com", Status: "current"}},
+		},
+		{
+			name:     "CSV with first and last names and semicolons",
+			filename: "roster.csv",
+			data:     "first_name;last_name;office\nBob;Jones;Paris\nCarol;;\n",
+			want:     []Employee{{Name: "Bob Jones", Location: "Paris"}, {Name: "Carol"}},
+		},
+		{
+			name:     "CSV without a name column",
+			filename: "roster.csv",
+			data:     "email,title\nalice@acme.com,Engineer\n",
+			wantErr:  true,
+		},
+		{
+			name:     "no employees",
+			filename: "roster.json",
+			data:     `[]`,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid JSON",
+			filename: "roster.json",
+			data:     `[{"name":`,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), tt.filename)
+			if err := os.WriteFile(filename, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := loadEmployees(filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadEmployees() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadEmployees() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveEmployeeListRoundTrip(t *testing.T) {
+	employees := []Employee{
+		{URN: "urn:li:member:1", Name: "Alice, Smith", Location: "Berlin", Title: "Engineer", Email: "alice@acme.com", Status: "current", ProfileURL: "https://www.linkedin.com/in/alice"},
+		{Name: "Bob", Status: "past"},
+	}
+	for _, filename := range []string{"roster.json", "roster.csv", "ROSTER.CSV"} {
+		t.Run(filename, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), filename)
+			if _, err := saveEmployeeList(path, employees); err != nil {
+				t.Fatal(err)
+			}
+			got, err := loadEmployees(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, employees) {
+				t.Errorf("round trip = %+v, want %+v", got, employees)
+			}
+		})
+	}
+}
+
+func TestSaveEmployeeListDeduplicates(t *testing.T) {
+	alice := Employee{URN: "urn:li:member:1", Name: "Alice", Location: "Berlin"}
+	bob := Employee{Name: "Bob", Location: "Paris"}
+	tests := []struct {
+		name      string
+		employees []Employee
+		want      []Employee
+	}{
+		{name: "same URN", employees: []Employee{alice, bob, {URN: alice.URN, Name: "Alice S.", Location: "Berlin"}}, want: []Employee{alice, bob}},
+		{name: "same name and location without URN", employees: []Employee{bob, alice, bob}, want: []Employee{bob, alice}},
+		{name: "namesakes elsewhere kept", employees: []Employee{bob, {Name: "Bob", Location: "Lyon"}}, want: []Employee{bob, {Name: "Bob", Location: "Lyon"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "roster.json")
+			saved, err := saveEmployeeList(path, tt.employees)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved != len(tt.want) {
+				t.Errorf("saved %d employees, want %d", saved, len(tt.want))
+			}
+			got, err := loadEmployees(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("saved %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
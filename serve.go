@@ -0,0 +1,581 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// States of a mulef serve job.
+const (
+	jobQueued    = "queued"
+	jobFetching  = "fetching"
+	jobMatching  = "matching"
+	jobDone      = "done"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+// maxJobRequestSize bounds the body of POST /jobs, which may hold an employee
+// list or a HAR export.
+const maxJobRequestSize = 32 << 20
+
+// jobRequest is the body of POST /jobs. What it leaves out is taken from the
+// flags and config profile mulef serve was started with.
+type jobRequest struct {
+	// Request is a captured LinkedIn search in any format -LinkedInRequest
+	// reads: raw, Burp, HAR or "Copy as cURL".
+	Request    string     `json:"request"`
+	Company    string     `json:"company"`
+	Employees  []Employee `json:"employees"`
+	Employment string     `json:"employment"`
+	Partition  string     `json:"partition"`
+	Mode       string     `json:"mode"`
+	Keywords   []string   `json:"keywords"`
+	Backend    string     `json:"backend"`
+	Threads    int        `json:"threads"`
+	Engagement string     `json:"engagement"`
+}
+
+// jobStatus is how the API reports a job.
+type jobStatus struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Engagement string     `json:"engagement,omitempty"`
+	Mode       string     `json:"mode"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Employees  int        `json:"employees"`
+	Processed  int        `json:"processed"`
+	Matches    int        `json:"matches"`
+	RunID      int64      `json:"run_id,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+func (s jobStatus) finished() bool {
+	return s.Status == jobDone || s.Status == jobFailed || s.Status == jobCancelled
+}
+
+// serverEvent is a server-sent event of a job.
+type serverEvent struct {
+	id   int
+	name string
+	data []byte
+}
+
+// job is a run submitted to mulef serve.
+type job struct {
+	linkedIn  linkedInOptions
+	github    githubOptions
+	employees []Employee
+	ctx       context.Context
+	cancel    context.CancelFunc
+
+	mu      sync.Mutex
+	status  jobStatus
+	results *Results
+	events  []serverEvent
+	// nextEvent is the ID of the next event.
+	nextEvent int
+	// changed is closed and replaced whenever an event is added.
+	changed chan struct{}
+}
+
+func (j *job) snapshot() jobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// emit records an event. A progress event replaces the progress event before
+// it, so a long run keeps one per match rather than one per employee. j.mu
+// must be held.
+func (j *job) emit(name string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	event := serverEvent{id: j.nextEvent, name: name, data: data}
+	j.nextEvent++
+	if last := len(j.events) - 1; name == "progress" && last >= 0 && j.events[last].name == "progress" {
+		j.events[last] = event
+	} else {
+		j.events = append(j.events, event)
+	}
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// update changes the status and sends it to the clients as the event name.
+func (j *job) update(name string, change func(status *jobStatus)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	change(&j.status)
+	j.emit(name, j.status)
+}
+
+// found sends a match to the clients as soon as it is found, before the run
+// assigns the logins found for several employees.
+func (j *job) found(match Match) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status.Matches++
+	j.emit("match", match)
+}
+
+// finish records how the run ended.
+func (j *job) finish(run matchRun, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.status.FinishedAt = &now
+	switch {
+	case err != nil && j.ctx.Err() != nil:
+		j.status.Status = jobCancelled
+	case err != nil:
+		j.status.Status = jobFailed
+		j.status.Error = err.Error()
+	default:
+		j.status.Status = jobDone
+		j.status.RunID = run.RunID
+		j.status.Matches = len(run.Results.Matches)
+		j.results = &run.Results
+	}
+	j.emit("done", j.status)
+	j.cancel()
+}
+
+// server runs the jobs submitted to mulef serve. Its options are the defaults
+// of every job.
+type server struct {
+	linkedIn linkedInOptions
+	github   githubOptions
+	apiToken string
+	// slots holds a token per running job.
+	slots chan struct{}
+	// jobTTL is how long finished jobs are kept, forever when 0.
+	jobTTL time.Duration
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	order  []*job
+	nextID int
+}
+
+// newJob checks a job request against the server defaults.
+func (s *server) newJob(request jobRequest) (*job, error) {
+	j := &job{linkedIn: s.linkedIn, github: s.github, employees: request.Employees, changed: make(chan struct{})}
+	// The results are served by the API, not written to a file.
+	j.github.results = ""
+	// The search of a job replaces the default one: its request is searched
+	// rather than the default company, and its company rather than the
+	// default request. Given both, the request holds the session for the
+	// company, as on the command line. Given only a company, the default
+	// request still holds the session when the server has no cookies.
+	if request.Request != "" || request.Company != "" {
+		keepSession := request.Request == "" && j.linkedIn.cookies == "" && os.Getenv(linkedInCookiesEnv) == ""
+		if !keepSession {
+			j.linkedIn.request, j.linkedIn.requestFile = nil, ""
+		}
+		j.linkedIn.company = request.Company
+	}
+	if request.Request != "" {
+		if _, err := parseCapturedRequest([]byte(request.Request)); err != nil {
+			return nil, fmt.Errorf("request: %v", err)
+		}
+		j.linkedIn.request = []byte(request.Request)
+	}
+	if request.Employment != "" {
+		j.linkedIn.employment = request.Employment
+	}
+	if request.Partition != "" {
+		j.linkedIn.partition = request.Partition
+	}
+	if len(j.employees) == 0 {
+		if j.linkedIn.request == nil && j.linkedIn.requestFile == "" && j.linkedIn.company == "" {
+			return nil, errors.New("a job needs a request, a company or employees")
+		}
+		if err := j.linkedIn.check(); err != nil {
+			return nil, err
+		}
+	}
+
+	if request.Mode != "" {
+		j.github.mode = request.Mode
+	}
+	if len(request.Keywords) > 0 {
+		j.github.keywords = strings.Join(request.Keywords, ",")
+	}
+	if request.Backend != "" {
+		j.github.backend = request.Backend
+	}
+	if request.Threads != 0 {
+		j.github.threads = request.Threads
+	}
+	if request.Engagement != "" {
+		j.github.engagement = request.Engagement
+	}
+	if err := j.github.check(); err != nil {
+		return nil, err
+	}
+	j.github.tokenSource = s.github.tokenSource
+
+	j.ctx, j.cancel = context.WithCancel(context.Background())
+	j.linkedIn.ctx, j.github.ctx = j.ctx, j.ctx
+	j.github.progress = func(done int, total int) {
+		j.update("progress", func(status *jobStatus) {
+			status.Processed, status.Employees = done, total
+		})
+	}
+	// The clients of the job hear of matches on their own path, the sinks only
+	// once the run is over.
+	j.github.found = j.found
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now())
+	s.nextID++
+	j.status = jobStatus{
+		ID:         strconv.Itoa(s.nextID),
+		Status:     jobQueued,
+		Engagement: j.github.engagement,
+		Mode:       j.github.mode,
+		CreatedAt:  time.Now(),
+		Employees:  len(j.employees),
+	}
+	j.emit("status", j.status)
+	s.jobs[j.status.ID] = j
+	s.order = append(s.order, j)
+	return j, nil
+}
+
+// run waits for a free slot and runs the job.
+func (s *server) run(j *job) {
+	select {
+	case s.slots <- struct{}{}:
+	case <-j.ctx.Done():
+		j.finish(matchRun{}, j.ctx.Err())
+		return
+	}
+	defer func() { <-s.slots }()
+
+	color.Cyan("[+] Starting job %s", j.status.ID)
+	employees := j.employees
+	if len(employees) == 0 {
+		j.update("status", func(status *jobStatus) {
+			now := time.Now()
+			status.Status, status.StartedAt = jobFetching, &now
+		})
+		var err error
+		if employees, err = j.linkedIn.fetch(); err != nil {
+			color.Red("[-] Job %s failed: %v", j.status.ID, err)
+			j.finish(matchRun{}, err)
+			return
+		}
+	}
+	j.update("status", func(status *jobStatus) {
+		if status.StartedAt == nil {
+			now := time.Now()
+			status.StartedAt = &now
+		}
+		status.Status, status.Employees = jobMatching, len(employees)
+	})
+	run, err := j.github.match(employees)
+	if err != nil {
+		color.Red("[-] Job %s failed: %v", j.status.ID, err)
+	} else {
+		color.Green("[*] Job %s done", j.status.ID)
+	}
+	j.finish(run, err)
+}
+
+// expire forgets the jobs that finished more than jobTTL before now. Their
+// runs stay in the store. s.mu must be held.
+func (s *server) expire(now time.Time) {
+	if s.jobTTL <= 0 {
+		return
+	}
+	kept := s.order[:0]
+	for _, j := range s.order {
+		status := j.snapshot()
+		if status.finished() && now.Sub(*status.FinishedAt) > s.jobTTL {
+			delete(s.jobs, status.ID)
+			continue
+		}
+		kept = append(kept, j)
+	}
+	s.order = kept
+}
+
+func (s *server) lookup(id string) *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[id]
+}
+
+// cancelAll cancels every job, when the server stops.
+func (s *server) cancelAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		j.cancel()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
+
+// ServeHTTP routes the API:
+//
+//	POST /jobs                submit a job
+//	GET  /jobs                list the jobs
+//	GET  /jobs/{id}           status and progress of a job
+//	GET  /jobs/{id}/results   results of a finished job
+//	GET  /jobs/{id}/events    status, progress and matches as server-sent events
+//	POST /jobs/{id}/cancel    cancel a job
+//	GET  /metrics             Prometheus metrics
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.apiToken != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.apiToken)) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or wrong API token")
+			return
+		}
+	}
+
+	if r.URL.Path == "/metrics" {
+		serveMetrics(w, r)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "jobs" || len(parts) > 3 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			s.listJobs(w)
+		case http.MethodPost:
+			s.submitJob(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "use GET or POST")
+		}
+		return
+	}
+
+	j := s.lookup(parts[1])
+	if j == nil {
+		writeError(w, http.StatusNotFound, "no job "+parts[1])
+		return
+	}
+	action := ""
+	if len(parts) == 3 {
+		action = parts[2]
+	}
+	method := http.MethodGet
+	if action == "cancel" {
+		method = http.MethodPost
+	}
+	if r.Method != method {
+		writeError(w, http.StatusMethodNotAllowed, "use "+method)
+		return
+	}
+
+	switch action {
+	case "":
+		writeJSON(w, http.StatusOK, j.snapshot())
+	case "results":
+		j.mu.Lock()
+		results, status := j.results, j.status
+		j.mu.Unlock()
+		if results == nil {
+			writeError(w, http.StatusConflict, "job "+status.ID+" is "+status.Status+", it has no results")
+			return
+		}
+		writeJSON(w, http.StatusOK, results)
+	case "events":
+		streamJob(w, r, j)
+	case "cancel":
+		if status := j.snapshot(); status.finished() {
+			writeError(w, http.StatusConflict, "job "+status.ID+" is already "+status.Status)
+			return
+		}
+		j.cancel()
+		color.Yellow("[!] Job %s cancelled", j.status.ID)
+		writeJSON(w, http.StatusAccepted, j.snapshot())
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *server) listJobs(w http.ResponseWriter) {
+	s.mu.Lock()
+	s.expire(time.Now())
+	jobs := append([]*job(nil), s.order...)
+	s.mu.Unlock()
+	statuses := []jobStatus{}
+	for _, j := range jobs {
+		statuses = append(statuses, j.snapshot())
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *server) submitJob(w http.ResponseWriter, r *http.Request) {
+	var request jobRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJobRequestSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid job: "+err.Error())
+		return
+	}
+	j, err := s.newJob(request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	color.Cyan("[+] Queued job %s (%s)", j.status.ID, valueOr(j.status.Engagement, "no engagement"))
+	go s.run(j)
+	w.Header().Set("Location", "/jobs/"+j.status.ID)
+	writeJSON(w, http.StatusAccepted, j.snapshot())
+}
+
+// streamJob sends the events of a job as server-sent events, starting after
+// Last-Event-ID when a client reconnects, until the job is finished.
+func streamJob(w http.ResponseWriter, r *http.Request, j *job) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	// next is the ID of the first event the client has not seen.
+	next := 0
+	if last, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil {
+		next = last + 1
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		j.mu.Lock()
+		first := sort.Search(len(j.events), func(i int) bool { return j.events[i].id >= next })
+		events := append([]serverEvent(nil), j.events[first:]...)
+		changed, finished := j.changed, j.status.finished()
+		j.mu.Unlock()
+
+		for _, event := range events {
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.id, event.name, event.data)
+			next = event.id + 1
+		}
+		flusher.Flush()
+		if finished {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func runServe(name string, args []string) error {
+	fs := newFlagSet(name, "Runs mulef as an HTTP API: jobs are submitted with POST /jobs, followed with GET /jobs/{id} or its server-sent events, and cancelled with POST /jobs/{id}/cancel. The other flags are the defaults of every job.")
+	var linkedIn linkedInOptions
+	var github githubOptions
+	var network networkOptions
+	var config configOptions
+	var sinks sinkOptions
+	linkedIn.register(fs)
+	github.register(fs)
+	network.register(fs)
+	config.register(fs)
+	sinks.register(fs)
+	listen := fs.String("listen", "127.0.0.1:8765", "address to listen on")
+	apiToken := fs.String("api-token", "", "token clients send as \"Authorization: Bearer <token>\", defaults to MULEF_API_TOKEN")
+	maxJobs := fs.Int("jobs", 1, "number of jobs run at the same time, the others wait")
+	jobTTL := fs.Duration("job-ttl", time.Hour, "how long finished jobs are kept, 0 keeps them until the server stops")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := config.apply(fs, "employees"); err != nil {
+		return err
+	}
+	github.profile = config.profile
+	if *maxJobs < 1 {
+		return usageError(fs, "Jobs must be at least 1")
+	}
+	if *jobTTL < 0 {
+		return usageError(fs, "Job TTL can not be negative")
+	}
+	if *apiToken == "" {
+		*apiToken = os.Getenv("MULEF_API_TOKEN")
+	}
+	token, source, err := resolveGitHubToken(github.token, github.tokenFile)
+	if err != nil {
+		return usageError(fs, err.Error())
+	}
+	github.token, github.tokenFile, github.tokenSource = token, "", source
+	if github.sinks, err = sinks.sinks(); err != nil {
+		return usageError(fs, err.Error())
+	}
+	if err := network.apply(); err != nil {
+		return err
+	}
+	if *apiToken == "" {
+		color.Yellow("[!] No -api-token given, anyone reaching %s can run jobs with your GitHub token and LinkedIn session", *listen)
+	}
+	if github.database == "" {
+		color.Yellow("[!] No -db given, the results of a job are only kept until the server stops")
+	}
+
+	s := &server{linkedIn: linkedIn, github: github, apiToken: *apiToken, slots: make(chan struct{}, *maxJobs), jobTTL: *jobTTL, jobs: make(map[string]*job)}
+	httpServer := &http.Server{Addr: *listen, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-interrupt
+		color.Cyan("[+] Stopping, running jobs are cancelled")
+		s.cancelAll()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		httpServer.Shutdown(ctx)
+	}()
+
+	color.Cyan("[+] Listening on http://%s", *listen)
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	<-stopped
+	return nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(apiToken string) *server {
+	return &server{
+		github:   githubOptions{mode: modeLocation, token: "t", threads: 1, backend: backendREST},
+		apiToken: apiToken,
+		slots:    make(chan struct{}, 1),
+		jobs:     make(map[string]*job),
+	}
+}
+
+func TestServeRoutes(t *testing.T) {
+	s := newTestServer("secret")
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		want   int
+	}{
+		{name: "no token", method: "GET", path: "/jobs", want: http.StatusUnauthorized},
+		{name: "wrong token", method: "GET", path: "/jobs", token: "guess", want: http.StatusUnauthorized},
+		{name: "list", method: "GET", path: "/jobs", token: "secret", want: http.StatusOK},
+		{name: "unknown path", method: "GET", path: "/runs", token: "secret", want: http.StatusNotFound},
+		{name: "unknown job", method: "GET", path: "/jobs/7", token: "secret", want: http.StatusNotFound},
+		{name: "wrong method", method: "DELETE", path: "/jobs", token: "secret", want: http.StatusMethodNotAllowed},
+		{name: "bad json", method: "POST", path: "/jobs", token: "secret", body: `{`, want: http.StatusBadRequest},
+		{name: "unknown field", method: "POST", path: "/jobs", token: "secret", body: `{"target":"acme"}`, want: http.StatusBadRequest},
+		{name: "nothing to match", method: "POST", path: "/jobs", token: "secret", body: `{}`, want: http.StatusBadRequest},
+		{name: "invalid mode", method: "POST", path: "/jobs", token: "secret", body: `{"employees":[{"name":"Alice"}],"mode":"guess"}`, want: http.StatusBadRequest},
+		{name: "keywords without keywords", method: "POST", path: "/jobs", token: "secret", body: `{"employees":[{"name":"Alice"}],"mode":"keywords"}`, want: http.StatusBadRequest},
+		{name: "invalid request", method: "POST", path: "/jobs", token: "secret", body: `{"request":"not a request"}`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+	if len(s.jobs) != 0 {
+		t.Errorf("rejected requests created %d jobs", len(s.jobs))
+	}
+}
+
+func TestServeJob(t *testing.T) {
+	withHTTPClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/rate_limit":
+			return stubResponse(http.StatusOK, `{"resources":{"core":{"limit":5000,"remaining":5000},"search":{"limit":30,"remaining":30}}}`), nil
+		case "/search/users":
+			return stubResponse(http.StatusOK, `{"total_count":1,"items":[{"login":"alice"}]}`), nil
+		case "/users/alice":
+			return stubResponse(http.StatusOK, `{"login":"alice","location":"Berlin","html_url":"https://github.com/alice"}`), nil
+		}
+		return stubResponse(http.StatusNotFound, `{}`), nil
+	}))
+	s := newTestServer("")
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/jobs", "application/json", strings.NewReader(`{"employees":[{"name":"Alice","location":"Berlin"}],"engagement":"acme"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted || resp.Header.Get("Location") != "/jobs/1" {
+		t.Fatalf("POST /jobs = %d, Location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	resp, err = http.Get(ts.URL + "/jobs/1/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	var done jobStatus
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event: ") {
+			names = append(names, strings.TrimPrefix(line, "event: "))
+		}
+		if strings.HasPrefix(line, "data: ") && names[len(names)-1] == "done" {
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &done)
+		}
+	}
+	resp.Body.Close()
+	if len(names) == 0 || names[0] != "status" || names[len(names)-1] != "done" {
+		t.Fatalf("events = %v, want status first and done last", names)
+	}
+	if !strings.Contains(strings.Join(names, " "), "match") {
+		t.Errorf("events = %v, want a match", names)
+	}
+	if done.Status != jobDone || done.Matches != 1 || done.Engagement != "acme" {
+		t.Errorf("done = %+v", done)
+	}
+
+	resp, err = http.Get(ts.URL + "/jobs/1/results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results Results
+	err = json.NewDecoder(resp.Body).Decode(&results)
+	resp.Body.Close()
+	if err != nil || len(results.Matches) != 1 || results.Matches[0].Login != "alice" {
+		t.Errorf("results = %+v, %v", results, err)
+	}
+
+	resp, err = http.Post(ts.URL+"/jobs/1/cancel", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("cancelling a finished job = %d, want %d", resp.StatusCode, http.StatusConflict)
+	}
+}
+
+func TestServeJobSearch(t *testing.T) {
+	captured := "curl '" + testSearchURL + "' -H 'Cookie: li_at=secret'"
+	s := newTestServer("")
+	t.Setenv(linkedInCookiesEnv, "")
+
+	tests := []struct {
+		name        string
+		cookies     string
+		request     jobRequest
+		wantFile    string
+		wantRequest bool
+		wantCompany string
+	}{
+		{name: "defaults", cookies: "li_at=x", wantFile: "default.txt", wantCompany: "default"},
+		{name: "request replaces the default company", cookies: "li_at=x", request: jobRequest{Request: captured}, wantRequest: true},
+		{name: "company replaces the default request", cookies: "li_at=x", request: jobRequest{Company: "acme"}, wantCompany: "acme"},
+		{name: "request holds the session of the company", cookies: "li_at=x", request: jobRequest{Request: captured, Company: "acme"}, wantRequest: true, wantCompany: "acme"},
+		{name: "default request holds the session without cookies", request: jobRequest{Company: "acme"}, wantFile: "default.txt", wantCompany: "acme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.linkedIn = linkedInOptions{requestFile: "default.txt", company: "default", cookies: tt.cookies, employment: employmentCurrent}
+			j, err := s.newJob(tt.request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer j.cancel()
+			if j.linkedIn.requestFile != tt.wantFile || (j.linkedIn.request != nil) != tt.wantRequest || j.linkedIn.company != tt.wantCompany {
+				t.Errorf("requestFile %q, request %v, company %q, want %q, %v, %q",
+					j.linkedIn.requestFile, j.linkedIn.request != nil, j.linkedIn.company, tt.wantFile, tt.wantRequest, tt.wantCompany)
+			}
+		})
+	}
+}
+
+func TestJobEvents(t *testing.T) {
+	j := &job{changed: make(chan struct{})}
+	j.emit("status", jobStatus{Status: jobQueued})
+	for i := 1; i <= 3; i++ {
+		j.emit("progress", jobStatus{Processed: i})
+	}
+	j.emit("match", Match{Login: "alice"})
+	j.emit("progress", jobStatus{Processed: 4})
+	j.emit("progress", jobStatus{Processed: 5})
+	j.status.Status = jobDone
+	j.emit("done", j.status)
+
+	tests := []struct {
+		name   string
+		lastID string
+		want   []string
+	}{
+		{name: "from the start", want: []string{"0 status", "3 progress", "4 match", "6 progress", "7 done"}},
+		{name: "after a coalesced event", lastID: "2", want: []string{"3 progress", "4 match", "6 progress", "7 done"}},
+		{name: "after the match", lastID: "4", want: []string{"6 progress", "7 done"}},
+		{name: "after the end", lastID: "7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/jobs/1/events", nil)
+			if tt.lastID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastID)
+			}
+			w := httptest.NewRecorder()
+			streamJob(w, req, j)
+
+			var got []string
+			var id string
+			for _, line := range strings.Split(w.Body.String(), "\n") {
+				if strings.HasPrefix(line, "id: ") {
+					id = strings.TrimPrefix(line, "id: ")
+				}
+				if strings.HasPrefix(line, "event: ") {
+					got = append(got, id+" "+strings.TrimPrefix(line, "event: "))
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerExpire(t *testing.T) {
+	now := time.Now()
+	finishedAt := func(age time.Duration) *time.Time {
+		at := now.Add(-age)
+		return &at
+	}
+	s := newTestServer("")
+	s.jobTTL = time.Hour
+	for _, status := range []jobStatus{
+		{ID: "1", Status: jobDone, FinishedAt: finishedAt(2 * time.Hour)},
+		{ID: "2", Status: jobMatching},
+		{ID: "3", Status: jobFailed, FinishedAt: finishedAt(time.Minute)},
+		{ID: "4", Status: jobCancelled, FinishedAt: finishedAt(90 * time.Minute)},
+	} {
+		j := &job{status: status}
+		s.jobs[status.ID] = j
+		s.order = append(s.order, j)
+	}
+
+	s.expire(now)
+	var ids []string
+	for _, j := range s.order {
+		ids = append(ids, j.status.ID)
+	}
+	if strings.Join(ids, ",") != "2,3" || len(s.jobs) != 2 || s.lookup("1") != nil {
+		t.Errorf("kept jobs %v (%d looked up), want 2 and 3", ids, len(s.jobs))
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// linkedInStatusThrottled is the non-standard status LinkedIn answers with
+// when it suspects automation.
+const linkedInStatusThrottled = 999
+
+// checkLinkedInCookies makes sure the captured request carries a usable
+// session: an li_at cookie and a csrf-token header matching JSESSIONID.
+func checkLinkedInCookies(captured capturedRequest) error {
+	if captured.cookie("li_at") == "" {
+		return errors.New("the request has no li_at cookie, copy it again from a browser where you are logged in to LinkedIn")
+	}
+
+	sessionID := captured.cookie("JSESSIONID")
+	csrfToken := strings.Trim(captured.Header.Get("Csrf-Token"), `"`)
+	switch {
+	case sessionID == "" && csrfToken == "":
+		return errors.New("the request has neither a JSESSIONID cookie nor a csrf-token header, LinkedIn will reject it")
+	case sessionID == "":
+		return errors.New("the request has a csrf-token header but no JSESSIONID cookie, copy the request again including its cookies")
+	case csrfToken == "":
+		return errors.New("the request has no csrf-token header, it must be set to the JSESSIONID cookie value (" + sessionID + ")")
+	case sessionID != csrfToken:
+		return fmt.Errorf("the csrf-token header (%s) does not match the JSESSIONID cookie (%s), the cookies and headers come from different sessions", csrfToken, sessionID)
+	}
+	return nil
+}
+
+// checkLinkedInResponse turns the ways LinkedIn refuses a request into
+// errors that tell the user what to do about it.
+func checkLinkedInResponse(resp *http.Response, body []byte) error {
+	location := resp.Header.Get("Location")
+	switch {
+	case resp.StatusCode == linkedInStatusThrottled:
+		return errors.New("LinkedIn is throttling this session (HTTP 999), wait a while, slow down or switch to another IP before retrying")
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return errors.New("LinkedIn rate limited the session (HTTP 429), wait a while before retrying")
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		if isChallengeText(location) {
+			return fmt.Errorf("LinkedIn wants to verify the session (redirected to %s), open LinkedIn in the browser, pass the check and copy the request again", location)
+		}
+		if isLoginText(location) {
+			return fmt.Errorf("the LinkedIn session has expired (redirected to %s), log in again and copy a fresh request", location)
+		}
+		return fmt.Errorf("LinkedIn redirected the request to %s, copy a fresh request from the browser", location)
+	case resp.StatusCode == http.StatusBadRequest && resp.Request != nil && strings.Contains(resp.Request.URL.RawQuery, "queryId="):
+		return errors.New("LinkedIn rejected the search (HTTP 400), its queryId is probably outdated, copy the current one from a search request in the browser's network tab and pass it with -query-id")
+	case resp.StatusCode == http.StatusUnauthorized:
+		return errors.New("the LinkedIn session is not valid anymore (HTTP 401), log in again and copy a fresh request")
+	case resp.StatusCode == http.StatusForbidden:
+		return errors.New("LinkedIn refused the request (HTTP 403), usually the csrf-token header does not belong to the session cookies, copy a fresh request")
+	}
+
+	if looksLikeHTML(resp, body) {
+		text := strings.ToLower(string(body))
+		switch {
+		case isChallengeText(text):
+			return errors.New("LinkedIn answered with a security challenge page, open LinkedIn in the browser, pass the check and copy the request again")
+		case isLoginText(text):
+			return errors.New("LinkedIn answered with its login page, the session has expired, log in again and copy a fresh request")
+		}
+		return fmt.Errorf("LinkedIn answered with an HTML page (HTTP %d) instead of JSON, make sure the request is the voyager graphql search request", resp.StatusCode)
+	}
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("LinkedIn answered with HTTP %d: %s", resp.StatusCode, snippet(body))
+	}
+	return nil
+}
+
+func looksLikeHTML(resp *http.Response, body []byte) bool {
+	if strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
+		return true
+	}
+	return strings.HasPrefix(strings.TrimSpace(string(body)), "<")
+}
+
+func isChallengeText(text string) bool {
+	return strings.Contains(text, "checkpoint/challenge") || strings.Contains(text, "captcha") || strings.Contains(text, "security verification")
+}
+
+func isLoginText(text string) bool {
+	return strings.Contains(text, "/login") || strings.Contains(text, "authwall") || strings.Contains(text, "sign in")
+}
+
+// snippet shortens a response body for error messages.
+func snippet(body []byte) string {
+	text := strings.TrimSpace(string(body))
+	if len(text) > 200 {
+		text = text[:200] + "..."
+	}
+	return text
+}
+
+// checkLinkedInSession is the preflight run before any search: it validates
+// the session cookies and fetches the first page of results, so an expired or
+// throttled session fails before the run starts rather than halfway through.
+// The page is returned for the search to start from.
+func checkLinkedInSession(captured capturedRequest) (linkedInPage, error) {
+	if err := checkLinkedInCookies(captured); err != nil {
+		return linkedInPage{}, err
+	}
+
+	body, err := getLinkedInResponse(captured, 0, searchSlice{})
+	if err != nil {
+		linkedInPagesMetric.add(1, "error")
+		return linkedInPage{}, err
+	}
+	linkedInPagesMetric.add(1, "ok")
+
+	var probe struct {
+		Data struct {
+			Data map[string]json.RawMessage `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return linkedInPage{}, fmt.Errorf("LinkedIn did not answer with JSON: %s", snippet(body))
+	}
+	if _, ok := probe.Data.Data["searchDashClustersByAll"]; !ok {
+		return linkedInPage{}, errors.New("the LinkedIn response has no search results, make sure the request is the voyager graphql people search request")
+	}
+	return parseLinkedInPage(body)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckLinkedInCookies(t *testing.T) {
+	tests := []struct {
+		name    string
+		cookie  string
+		csrf    string
+		wantErr string
+	}{
+		{name: "valid", cookie: `li_at=x; JSESSIONID="ajax:1"`, csrf: "ajax:1"},
+		{name: "no li_at", cookie: `JSESSIONID="ajax:1"`, csrf: "ajax:1", wantErr: "no li_at cookie"},
+		{name: "no session or token", cookie: "li_at=x", wantErr: "neither"},
+		{name: "no session", cookie: "li_at=x", csrf: "ajax:1", wantErr: "no JSESSIONID"},
+		{name: "no token", cookie: `li_at=x; JSESSIONID="ajax:1"`, wantErr: "no csrf-token"},
+		{name: "mismatch", cookie: `li_at=x; JSESSIONID="ajax:1"`, csrf: "ajax:2", wantErr: "does not match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captured := newCapturedRequest()
+			captured.addCookies(tt.cookie)
+			if tt.csrf != "" {
+				captured.Header.Set("Csrf-Token", tt.csrf)
+			}
+			err := checkLinkedInCookies(captured)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkLinkedInCookies() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkLinkedInCookies() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckLinkedInResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		location string
+		ctype    string
+		body     string
+		wantErr  string
+	}{
+		{name: "ok", status: 200, ctype: "application/json", body: `{"data":{}}`},
+		{name: "throttled", status: 999, wantErr: "HTTP 999"},
+		{name: "rate limited", status: 429, wantErr: "HTTP 429"},
+		{name: "challenge redirect", status: 302, location: "https://www.linkedin.com/checkpoint/challenge/x", wantErr: "verify the session"},
+		{name: "login redirect", status: 302, location: "https://www.linkedin.com/login", wantErr: "expired"},
+		{name: "other redirect", status: 301, location: "https://example.com/", wantErr: "redirected the request"},
+		{name: "unauthorized", status: 401, wantErr: "HTTP 401"},
+		{name: "forbidden", status: 403, wantErr: "HTTP 403"},
+		{name: "login page", status: 200, ctype: "text/html", body: "<html>Sign in to LinkedIn</html>", wantErr: "login page"},
+		{name: "challenge page", status: 200, body: "<html>captcha</html>", wantErr: "security challenge"},
+		{name: "other page", status: 200, body: "<html></html>", wantErr: "HTML page"},
+		{name: "server error", status: 500, body: "oops", wantErr: "HTTP 500: oops"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: make(http.Header)}
+			if tt.location != "" {
+				resp.Header.Set("Location", tt.location)
+			}
+			if tt.ctype != "" {
+				resp.Header.Set("Content-Type", tt.ctype)
+			}
+			err := checkLinkedInResponse(resp, []byte(tt.body))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkLinkedInResponse() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkLinkedInResponse() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckLinkedInSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{name: "search results", body: `{"data":{"data":{"searchDashClustersByAll":{}}}}`},
+		{name: "other query", body: `{"data":{"data":{"identityDashProfiles":{}}}}`, wantErr: true},
+		{name: "not JSON", body: `nope`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			captured := newCapturedRequest()
+			captured.Method = http.MethodGet
+			captured.URL = server.URL + "/voyager/api/graphql?variables=(start:0)"
+			captured.addCookies(`li_at=x; JSESSIONID="ajax:1"`)
+			captured.Header.Set("Csrf-Token", "ajax:1")
+
+			_, err := checkLinkedInSession(captured)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkLinkedInSession() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
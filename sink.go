@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Event types sent to sinks.
+const (
+	eventMatch = "match"
+	eventDelta = "delta"
+)
+
+// Event is what mulef sends to sinks: a match kept at the end of a run, once
+// logins found for several employees are assigned, or what changed since the
+// previous run of mulef watch.
+type Event struct {
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	Engagement string    `json:"engagement,omitempty"`
+	Match      *Match    `json:"match,omitempty"`
+	Diff       *Diff     `json:"diff,omitempty"`
+}
+
+// Sink receives events, for instance to alert a team or open a ticket. ctx
+// ends the run, and with it any retry going on.
+type Sink interface {
+	Send(ctx context.Context, event Event) error
+	String() string
+}
+
+// sinkTypes create a sink from what follows "<type>:" in a -sink value. New
+// kinds of sinks only need an entry here.
+var sinkTypes = map[string]func(target string, options *sinkOptions) (Sink, error){
+	"file": func(target string, options *sinkOptions) (Sink, error) {
+		if target == "" {
+			return nil, errors.New("file sink has no path")
+		}
+		return &fileSink{path: target}, nil
+	},
+	"webhook": func(target string, options *sinkOptions) (Sink, error) {
+		return newWebhookSink(target, options)
+	},
+}
+
+// sinkOptions are the -sink flags and the settings of webhook sinks.
+type sinkOptions struct {
+	specs       []string
+	template    string
+	secret      string
+	retries     int
+	contentType string
+}
+
+func (o *sinkOptions) register(fs *flag.FlagSet) {
+	fs.Var((*stringsFlag)(&o.specs), "sink", "where to send events: file:<path> or webhook:<url> (repeatable)")
+	fs.StringVar(&o.template, "webhook-template", "", "path of a Go template for the webhook body, the event is sent as JSON without one")
+	fs.StringVar(&o.secret, "webhook-secret", "", "sign webhook bodies with HMAC-SHA256 using this secret, defaults to MULEF_WEBHOOK_SECRET")
+	fs.IntVar(&o.retries, "webhook-retries", 3, "how often a failed webhook is retried")
+	fs.StringVar(&o.contentType, "webhook-content-type", "application/json", "content type of the webhook body")
+}
+
+// sinks creates the sinks given with -sink.
+func (o *sinkOptions) sinks() ([]Sink, error) {
+	if o.secret == "" {
+		o.secret = os.Getenv("MULEF_WEBHOOK_SECRET")
+	}
+	if o.retries < 0 {
+		return nil, errors.New("webhook retries can not be negative")
+	}
+	var sinks []Sink
+	for _, spec := range o.specs {
+		sink, err := parseSink(spec, o)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// parseSink reads a sink given as <type>:<target>. A bare http or https URL
+// is a webhook.
+func parseSink(spec string, options *sinkOptions) (Sink, error) {
+	if strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://") {
+		return newWebhookSink(spec, options)
+	}
+	kind, target, _ := strings.Cut(spec, ":")
+	create, ok := sinkTypes[kind]
+	if !ok {
+		return nil, fmt.Errorf("invalid sink %q, use file:<path> or webhook:<url>", spec)
+	}
+	return create(target, options)
+}
+
+// stringsFlag collects the values of a repeatable flag.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// sendEvents sends events to every sink in order. Each sink gets its own
+// goroutine, so a slow or failing webhook does not hold up the others.
+func sendEvents(ctx context.Context, sinks []Sink, events []Event) {
+	var wg sync.WaitGroup
+	for _, sink := range sinks {
+		wg.Add(1)
+		go func(sink Sink) {
+			defer wg.Done()
+			for _, event := range events {
+				if err := sink.Send(ctx, event); err != nil {
+					color.Red("[-] Can not send the %s event to %s: %v", event.Type, sink, err)
+				}
+			}
+		}(sink)
+	}
+	wg.Wait()
+}
+
+// fileSink appends every event as a line of JSON.
+type fileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (s *fileSink) String() string {
+	return "file:" + s.path
+}
+
+func (s *fileSink) Send(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// webhookClient posts webhooks. They go to the team's own services rather
+// than the targets, so unlike httpClient it ignores -proxy and -insecure.
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// maxWebhookPause caps the pause between two attempts, whatever the receiver
+// asks for with Retry-After.
+const maxWebhookPause = time.Minute
+
+// webhookSignatureHeader carries the HMAC-SHA256 of the body, hex encoded and
+// prefixed with sha256= the way GitHub signs its webhooks.
+const webhookSignatureHeader = "X-Mulef-Signature-256"
+
+// webhookSink posts every event, as JSON or rendered with a template.
+type webhookSink struct {
+	url         string
+	template    *template.Template
+	secret      string
+	retries     int
+	contentType string
+}
+
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	"join": strings.Join,
+}
+
+func newWebhookSink(url string, options *sinkOptions) (*webhookSink, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("invalid webhook URL %q", url)
+	}
+	sink := &webhookSink{url: url, secret: options.secret, retries: options.retries, contentType: options.contentType}
+	if sink.contentType == "" {
+		sink.contentType = "application/json"
+	}
+	if options.template != "" {
+		text, err := ioutil.ReadFile(options.template)
+		if err != nil {
+			return nil, err
+		}
+		if sink.template, err = template.New("webhook").Funcs(webhookTemplateFuncs).Parse(string(text)); err != nil {
+			return nil, fmt.Errorf("webhook template: %v", err)
+		}
+	}
+	return sink, nil
+}
+
+func (s *webhookSink) String() string {
+	return "webhook:" + s.url
+}
+
+func (s *webhookSink) body(event Event) ([]byte, error) {
+	if s.template == nil {
+		return json.Marshal(event)
+	}
+	var body bytes.Buffer
+	if err := s.template.Execute(&body, event); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+// Send posts the event, retrying with a growing pause when the request fails,
+// the receiver errs or asks to slow down. Other client errors are final.
+func (s *webhookSink) Send(ctx context.Context, event Event) error {
+	body, err := s.body(event)
+	if err != nil {
+		return err
+	}
+
+	pause := time.Second
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := s.post(ctx, event, body)
+		if err == nil {
+			return nil
+		}
+		var final *finalError
+		if errors.As(err, &final) || attempt >= s.retries || ctx.Err() != nil {
+			return err
+		}
+		pause = webhookPause(pause, retryAfter)
+		select {
+		case <-time.After(pause):
+		case <-ctx.Done():
+			return err
+		}
+		pause *= 2
+	}
+}
+
+// webhookPause is how long to wait before the next attempt: what the receiver
+// asked for with Retry-After, or else pause, at most maxWebhookPause.
+func webhookPause(pause, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		pause = retryAfter
+	}
+	if pause > maxWebhookPause {
+		pause = maxWebhookPause
+	}
+	return pause
+}
+
+// finalError is a webhook failure not worth retrying.
+type finalError struct {
+	err error
+}
+
+func (e *finalError) Error() string {
+	return e.err.Error()
+}
+
+func (s *webhookSink) post(ctx context.Context, event Event, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, &finalError{err}
+	}
+	req.Header.Set("Content-Type", s.contentType)
+	req.Header.Set("User-Agent", "mulef")
+	req.Header.Set("X-Mulef-Event", event.Type)
+	if s.secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.secret))
+		mac.Write(body)
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	response, _ := ioutil.ReadAll(resp.Body)
+	switch {
+	case resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return time.Duration(seconds) * time.Second, fmt.Errorf("%s answered HTTP %d: %s", s.url, resp.StatusCode, snippet(response))
+	}
+	return 0, &finalError{fmt.Errorf("%s answered HTTP %d: %s", s.url, resp.StatusCode, snippet(response))}
+}
+
+// sampleEvent is sent by mulef sink test.
+func sampleEvent() Event {
+	employee := Employee{Name: "Jane Doe", Title: "Software Engineer", Location: "Berlin", Status: employmentCurrent}
+	match := Match{
+		Employee:   employee,
+		Login:      "janedoe",
+		ProfileURL: "https://github.com/janedoe",
+		Name:       "Jane Doe",
+		Location:   "Berlin, Germany",
+		Score:      5,
+		Evidence:   []Evidence{{Kind: evidenceOrgMember, Detail: "public member of acme", URL: "https://github.com/orgs/acme/people"}},
+	}
+	return Event{Type: eventMatch, Time: time.Now(), Engagement: "test", Match: &match}
+}
+
+func runSink(args []string) error {
+	if len(args) == 0 || args[0] != "test" {
+		fmt.Fprint(os.Stderr, "Usage: mulef sink test -sink <sink> [flags]\n")
+		return flag.ErrHelp
+	}
+	fs := newFlagSet("mulef sink test", "Sends a made up match event to the sinks, to check a webhook, its template and signature against a listener.")
+	var options sinkOptions
+	var network networkOptions
+	options.register(fs)
+	network.register(fs)
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	sinks, err := options.sinks()
+	if err != nil {
+		return usageError(fs, err.Error())
+	}
+	if len(sinks) == 0 {
+		return usageError(fs, "Sink flag not specified")
+	}
+	if err := network.apply(); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, sink := range sinks {
+		if err := sink.Send(context.Background(), sampleEvent()); err != nil {
+			color.Red("[-] %s: %v", sink, err)
+			failed++
+			continue
+		}
+		color.Green("[*] Sent a test event to %s", sink)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d sinks failed", failed, len(sinks))
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseSink(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{spec: "file:events.jsonl", want: "file:events.jsonl"},
+		{spec: "webhook:https://hooks.example.com/x", want: "webhook:https://hooks.example.com/x"},
+		{spec: "https://hooks.example.com/x", want: "webhook:https://hooks.example.com/x"},
+		{spec: "file:", wantErr: true},
+		{spec: "webhook:ftp://hooks.example.com", wantErr: true},
+		{spec: "slack", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			sink, err := parseSink(tt.spec, &sinkOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSink() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && sink.String() != tt.want {
+				t.Errorf("parseSink() = %s, want %s", sink, tt.want)
+			}
+		})
+	}
+}
+
+func TestSinkOptionsSinks(t *testing.T) {
+	t.Setenv("MULEF_WEBHOOK_SECRET", "from-env")
+	options := sinkOptions{specs: []string{"file:a.jsonl", "https://hooks.example.com/x"}, retries: 2}
+	sinks, err := options.sinks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sinks) != 2 {
+		t.Fatalf("got %d sinks, want 2", len(sinks))
+	}
+	webhook := sinks[1].(*webhookSink)
+	if webhook.secret != "from-env" || webhook.retries != 2 || webhook.contentType != "application/json" {
+		t.Errorf("webhook = %+v", webhook)
+	}
+
+	options = sinkOptions{retries: -1}
+	if _, err := options.sinks(); err == nil {
+		t.Error("negative retries accepted")
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink := &fileSink{path: path}
+	for _, engagement := range []string{"acme", "other"} {
+		if err := sink.Send(context.Background(), Event{Type: eventDelta, Engagement: engagement, Diff: &Diff{Old: "run 1", New: "run 2"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	var event Event
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Engagement != "other" || event.Diff == nil || event.Diff.New != "run 2" {
+		t.Errorf("event = %+v", event)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	templateFile := filepath.Join(t.TempDir(), "slack.tmpl")
+	if err := os.WriteFile(templateFile, []byte(`{"text": {{json (printf "%s found %s" .Engagement .Match.Login)}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		options      sinkOptions
+		statuses     []int
+		retryAfter   string
+		wantAttempts int
+		wantBody     string
+		wantErr      bool
+	}{
+		{
+			name:         "signed JSON",
+			options:      sinkOptions{secret: "s3cret"},
+			statuses:     []int{http.StatusOK},
+			wantAttempts: 1,
+		},
+		{
+			name:         "template",
+			options:      sinkOptions{template: templateFile, contentType: "application/vnd.test+json"},
+			statuses:     []int{http.StatusNoContent},
+			wantAttempts: 1,
+			wantBody:     `{"text": "test found janedoe"}`,
+		},
+		{
+			name:         "server error is retried",
+			options:      sinkOptions{retries: 3, secret: "s3cret"},
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusOK},
+			wantAttempts: 2,
+		},
+		{
+			name:         "rate limit is retried after Retry-After",
+			options:      sinkOptions{retries: 1},
+			statuses:     []int{http.StatusTooManyRequests, http.StatusAccepted},
+			retryAfter:   "1",
+			wantAttempts: 2,
+		},
+		{
+			name:         "retries run out",
+			options:      sinkOptions{retries: 1},
+			statuses:     []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusOK},
+			wantAttempts: 2,
+			wantErr:      true,
+		},
+		{
+			name:         "client error is final",
+			options:      sinkOptions{retries: 3},
+			statuses:     []int{http.StatusBadRequest, http.StatusOK},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				mu.Lock()
+				status := tt.statuses[attempts]
+				attempts++
+				mu.Unlock()
+
+				if r.Header.Get("X-Mulef-Event") != eventMatch {
+					t.Errorf("X-Mulef-Event = %q", r.Header.Get("X-Mulef-Event"))
+				}
+				wantType := tt.options.contentType
+				if wantType == "" {
+					wantType = "application/json"
+				}
+				if got := r.Header.Get("Content-Type"); got != wantType {
+					t.Errorf("Content-Type = %q, want %q", got, wantType)
+				}
+				signature := r.Header.Get(webhookSignatureHeader)
+				if tt.options.secret == "" {
+					if signature != "" {
+						t.Errorf("unsigned webhook has signature %q", signature)
+					}
+				} else {
+					mac := hmac.New(sha256.New, []byte(tt.options.secret))
+					mac.Write(body)
+					if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); !hmac.Equal([]byte(signature), []byte(want)) {
+						t.Errorf("signature = %q, want %q", signature, want)
+					}
+				}
+				if tt.wantBody != "" && string(body) != tt.wantBody {
+					t.Errorf("body = %s, want %s", body, tt.wantBody)
+				}
+				if tt.wantBody == "" {
+					var event Event
+					if err := json.Unmarshal(body, &event); err != nil || event.Match == nil || event.Match.Login != "janedoe" {
+						t.Errorf("body = %s", body)
+					}
+				}
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			sink, err := newWebhookSink(server.URL, &tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = sink.Send(context.Background(), sampleEvent())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestWebhookPause(t *testing.T) {
+	tests := []struct {
+		name       string
+		pause      time.Duration
+		retryAfter time.Duration
+		want       time.Duration
+	}{
+		{name: "backoff", pause: 4 * time.Second, want: 4 * time.Second},
+		{name: "Retry-After", pause: 4 * time.Second, retryAfter: 10 * time.Second, want: 10 * time.Second},
+		{name: "Retry-After capped", pause: time.Second, retryAfter: time.Hour, want: maxWebhookPause},
+		{name: "backoff capped", pause: 2 * maxWebhookPause, want: maxWebhookPause},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := webhookPause(tt.pause, tt.retryAfter); got != tt.want {
+				t.Errorf("webhookPause(%v, %v) = %v, want %v", tt.pause, tt.retryAfter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookSinkCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	sink, err := newWebhookSink(server.URL, &sinkOptions{retries: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	if err := sink.Send(ctx, sampleEvent()); err == nil {
+		t.Error("Send() succeeded")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("Send() took %s after the context was cancelled", elapsed)
+	}
+}
+
+func TestWebhookSinkIgnoresProxy(t *testing.T) {
+	// httpClient stands for the one -proxy and -insecure configure.
+	withHTTPClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("sent through the proxy")
+	}))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	sink, err := newWebhookSink(server.URL, &sinkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(context.Background(), sampleEvent()); err != nil {
+		t.Errorf("Send() error = %v", err)
+	}
+}
+
+func TestNewWebhookSinkTemplateErrors(t *testing.T) {
+	broken := filepath.Join(t.TempDir(), "broken.tmpl")
+	if err := os.WriteFile(broken, []byte(`{{.Match`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, template := range []string{broken, filepath.Join(t.TempDir(), "missing.tmpl")} {
+		if _, err := newWebhookSink("https://hooks.example.com", &sinkOptions{template: template}); err == nil {
+			t.Errorf("template %s accepted", template)
+		}
+	}
+}
+
+// recordingSink keeps the events it is sent, after waiting delay.
+type recordingSink struct {
+	name  string
+	delay time.Duration
+	fail  bool
+
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *recordingSink) String() string {
+	return s.name
+}
+
+func (s *recordingSink) Send(ctx context.Context, event Event) error {
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	if s.fail {
+		return errors.New("refused")
+	}
+	return nil
+}
+
+func (s *recordingSink) logins() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var logins []string
+	for _, event := range s.events {
+		logins = append(logins, event.Match.Login)
+	}
+	return logins
+}
+
+func TestSendEvents(t *testing.T) {
+	var events []Event
+	for _, login := range []string{"a", "b", "c"} {
+		events = append(events, Event{Type: eventMatch, Match: &Match{Login: login}})
+	}
+	slow := &recordingSink{name: "slow", delay: 30 * time.Millisecond}
+	failing := &recordingSink{name: "failing", delay: 30 * time.Millisecond, fail: true}
+	fast := &recordingSink{name: "fast"}
+
+	started := time.Now()
+	sendEvents(context.Background(), []Sink{slow, failing, fast}, events)
+	// Each slow sink takes 90ms, one after the other they would take 180ms.
+	if elapsed := time.Since(started); elapsed > 150*time.Millisecond {
+		t.Errorf("sendEvents took %s, the sinks were not sent to side by side", elapsed)
+	}
+	for _, sink := range []*recordingSink{slow, failing, fast} {
+		if got := sink.logins(); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+			t.Errorf("%s got %v, want every event in order", sink, got)
+		}
+	}
+}
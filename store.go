@@ -0,0 +1,518 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	_ "modernc.org/sqlite"
+)
+
+// storeMigrations build the schema of the results store. Each entry is one
+// schema version, applied in order and recorded in schema_migrations, so
+// new versions are appended and existing ones never change.
+var storeMigrations = []string{
+	`CREATE TABLE runs (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		engagement  TEXT NOT NULL DEFAULT '',
+		started_at  TEXT NOT NULL,
+		finished_at TEXT NOT NULL,
+		mode        TEXT NOT NULL DEFAULT '',
+		keywords    TEXT NOT NULL DEFAULT '[]'
+	);
+	CREATE INDEX runs_engagement ON runs (engagement, started_at);
+
+	CREATE TABLE employees (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id      INTEGER NOT NULL REFERENCES runs (id) ON DELETE CASCADE,
+		key         TEXT NOT NULL,
+		urn         TEXT NOT NULL DEFAULT '',
+		name        TEXT NOT NULL DEFAULT '',
+		location    TEXT NOT NULL DEFAULT '',
+		title       TEXT NOT NULL DEFAULT '',
+		email       TEXT NOT NULL DEFAULT '',
+		status      TEXT NOT NULL DEFAULT '',
+		profile_url TEXT NOT NULL DEFAULT '',
+		UNIQUE (run_id, key)
+	);
+
+	CREATE TABLE candidates (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id      INTEGER NOT NULL REFERENCES runs (id) ON DELETE CASCADE,
+		employee_id INTEGER NOT NULL REFERENCES employees (id) ON DELETE CASCADE,
+		login       TEXT NOT NULL,
+		profile_url TEXT NOT NULL DEFAULT '',
+		name        TEXT NOT NULL DEFAULT '',
+		location    TEXT NOT NULL DEFAULT '',
+		avatar_url  TEXT NOT NULL DEFAULT '',
+		score       REAL NOT NULL DEFAULT 0,
+		ambiguous   INTEGER NOT NULL DEFAULT 0,
+		contenders  TEXT NOT NULL DEFAULT '[]',
+		decision    TEXT NOT NULL DEFAULT '',
+		discarded   INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX candidates_login ON candidates (login);
+
+	CREATE TABLE signals (
+		run_id INTEGER NOT NULL REFERENCES runs (id) ON DELETE CASCADE,
+		kind   TEXT NOT NULL,
+		weight REAL NOT NULL,
+		PRIMARY KEY (run_id, kind)
+	);
+
+	CREATE TABLE evidence (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		candidate_id INTEGER NOT NULL REFERENCES candidates (id) ON DELETE CASCADE,
+		kind         TEXT NOT NULL,
+		keyword      TEXT NOT NULL DEFAULT '',
+		detail       TEXT NOT NULL DEFAULT '',
+		url          TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX evidence_kind ON evidence (kind);`,
+}
+
+// store keeps the results of every run in a SQLite database so runs can be
+// queried and compared across engagements.
+type store struct {
+	db *sql.DB
+}
+
+// storedRun summarises a run in the store.
+type storedRun struct {
+	ID         int64
+	Engagement string
+	StartedAt  time.Time
+	Mode       string
+	Employees  int
+	Matches    int
+}
+
+func openStore(path string) (*store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer, a single connection avoids busy errors
+	// between the workers of one process.
+	db.SetMaxOpenConns(1)
+	s := &store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+func (s *store) close() error {
+	return s.db.Close()
+}
+
+// migrate applies the migrations the database has not seen yet.
+func (s *store) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at TEXT NOT NULL)`); err != nil {
+		return err
+	}
+	var version int
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(storeMigrations) {
+		return fmt.Errorf("the store has schema version %d, newer than this mulef knows (%d)", version, len(storeMigrations))
+	}
+	for ; version < len(storeMigrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(storeMigrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %v", version+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version+1, formatTime(time.Now())); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(value string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, value)
+	return t
+}
+
+// saveRun stores results as a new run and returns its ID.
+func (s *store) saveRun(results Results) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	keywords, _ := json.Marshal(results.Keywords)
+	res, err := tx.Exec(`INSERT INTO runs (engagement, started_at, finished_at, mode, keywords) VALUES (?, ?, ?, ?, ?)`,
+		results.Engagement, formatTime(results.StartedAt), formatTime(results.FinishedAt), results.Mode, string(keywords))
+	if err != nil {
+		return 0, err
+	}
+	runID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	weights := results.Weights
+	if weights == nil {
+		weights = defaultScoringWeights
+	}
+	for kind, weight := range weights {
+		if _, err := tx.Exec(`INSERT INTO signals (run_id, kind, weight) VALUES (?, ?, ?)`, runID, kind, weight); err != nil {
+			return 0, err
+		}
+	}
+
+	employeeIDs := make(map[string]int64)
+	saveEmployee := func(employee Employee) (int64, error) {
+		key := employeeKey(employee)
+		if id, ok := employeeIDs[key]; ok {
+			return id, nil
+		}
+		res, err := tx.Exec(`INSERT INTO employees (run_id, key, urn, name, location, title, email, status, profile_url) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			runID, key, employee.URN, employee.Name, employee.Location, employee.Title, employee.Email, employee.Status, employee.ProfileURL)
+		if err != nil {
+			return 0, err
+		}
+		id, err := res.LastInsertId()
+		employeeIDs[key] = id
+		return id, err
+	}
+	for _, employee := range results.Employees {
+		if _, err := saveEmployee(employee); err != nil {
+			return 0, err
+		}
+	}
+
+	saveMatch := func(match Match, discarded bool) error {
+		employeeID, err := saveEmployee(match.Employee)
+		if err != nil {
+			return err
+		}
+		contenders, _ := json.Marshal(match.Contenders)
+		res, err := tx.Exec(`INSERT INTO candidates (run_id, employee_id, login, profile_url, name, location, avatar_url, score, ambiguous, contenders, decision, discarded) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			runID, employeeID, match.Login, match.ProfileURL, match.Name, match.Location, match.AvatarURL, match.Score, match.Ambiguous, string(contenders), match.Decision, discarded)
+		if err != nil {
+			return err
+		}
+		candidateID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for _, evidence := range match.Evidence {
+			if _, err := tx.Exec(`INSERT INTO evidence (candidate_id, kind, keyword, detail, url) VALUES (?, ?, ?, ?, ?)`,
+				candidateID, evidence.Kind, evidence.Keyword, evidence.Detail, evidence.URL); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, match := range results.Matches {
+		if err := saveMatch(match, false); err != nil {
+			return 0, err
+		}
+	}
+	for _, match := range results.Discarded {
+		if err := saveMatch(match, true); err != nil {
+			return 0, err
+		}
+	}
+	return runID, tx.Commit()
+}
+
+// runs lists the runs of an engagement, or of every engagement when it is
+// empty, oldest first.
+func (s *store) runs(engagement string) ([]storedRun, error) {
+	rows, err := s.db.Query(`SELECT r.id, r.engagement, r.started_at, r.mode,
+			(SELECT COUNT(*) FROM employees e WHERE e.run_id = r.id),
+			(SELECT COUNT(*) FROM candidates c WHERE c.run_id = r.id AND c.discarded = 0)
+		FROM runs r WHERE ? = '' OR r.engagement = ? ORDER BY r.started_at, r.id`, engagement, engagement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []storedRun
+	for rows.Next() {
+		var run storedRun
+		var startedAt string
+		if err := rows.Scan(&run.ID, &run.Engagement, &startedAt, &run.Mode, &run.Employees, &run.Matches); err != nil {
+			return nil, err
+		}
+		run.StartedAt = parseTime(startedAt)
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// loadRun reads a run back into the shape of a results file.
+func (s *store) loadRun(runID int64) (Results, error) {
+	var results Results
+	var startedAt, finishedAt, keywords string
+	err := s.db.QueryRow(`SELECT engagement, started_at, finished_at, mode, keywords FROM runs WHERE id = ?`, runID).
+		Scan(&results.Engagement, &startedAt, &finishedAt, &results.Mode, &keywords)
+	if err == sql.ErrNoRows {
+		return results, fmt.Errorf("there is no run %d in the store", runID)
+	}
+	if err != nil {
+		return results, err
+	}
+	results.StartedAt, results.FinishedAt = parseTime(startedAt), parseTime(finishedAt)
+	json.Unmarshal([]byte(keywords), &results.Keywords)
+
+	results.Weights = make(map[string]float64)
+	signals, err := s.db.Query(`SELECT kind, weight FROM signals WHERE run_id = ?`, runID)
+	if err != nil {
+		return results, err
+	}
+	for signals.Next() {
+		var kind string
+		var weight float64
+		if err := signals.Scan(&kind, &weight); err != nil {
+			signals.Close()
+			return results, err
+		}
+		results.Weights[kind] = weight
+	}
+	signals.Close()
+
+	employees := make(map[int64]Employee)
+	rows, err := s.db.Query(`SELECT id, urn, name, location, title, email, status, profile_url FROM employees WHERE run_id = ? ORDER BY id`, runID)
+	if err != nil {
+		return results, err
+	}
+	for rows.Next() {
+		var id int64
+		var employee Employee
+		if err := rows.Scan(&id, &employee.URN, &employee.Name, &employee.Location, &employee.Title, &employee.Email, &employee.Status, &employee.ProfileURL); err != nil {
+			rows.Close()
+			return results, err
+		}
+		employees[id] = employee
+		results.Employees = append(results.Employees, employee)
+	}
+	rows.Close()
+
+	evidence := make(map[int64][]Evidence)
+	rows, err = s.db.Query(`SELECT v.candidate_id, v.kind, v.keyword, v.detail, v.url FROM evidence v JOIN candidates c ON c.id = v.candidate_id WHERE c.run_id = ? ORDER BY v.id`, runID)
+	if err != nil {
+		return results, err
+	}
+	for rows.Next() {
+		var candidateID int64
+		var e Evidence
+		if err := rows.Scan(&candidateID, &e.Kind, &e.Keyword, &e.Detail, &e.URL); err != nil {
+			rows.Close()
+			return results, err
+		}
+		evidence[candidateID] = append(evidence[candidateID], e)
+	}
+	rows.Close()
+
+	rows, err = s.db.Query(`SELECT id, employee_id, login, profile_url, name, location, avatar_url, score, ambiguous, contenders, decision, discarded FROM candidates WHERE run_id = ? ORDER BY id`, runID)
+	if err != nil {
+		return results, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, employeeID int64
+		var match Match
+		var contenders string
+		var discarded bool
+		if err := rows.Scan(&id, &employeeID, &match.Login, &match.ProfileURL, &match.Name, &match.Location, &match.AvatarURL, &match.Score, &match.Ambiguous, &contenders, &match.Decision, &discarded); err != nil {
+			return results, err
+		}
+		match.Employee = employees[employeeID]
+		match.Evidence = evidence[id]
+		json.Unmarshal([]byte(contenders), &match.Contenders)
+		if discarded {
+			results.Discarded = append(results.Discarded, match)
+		} else {
+			results.Matches = append(results.Matches, match)
+		}
+	}
+	return results, rows.Err()
+}
+
+// latestRun returns the newest run of an engagement, or of every engagement
+// when it is empty.
+func (s *store) latestRun(engagement string) (int64, error) {
+	runs, err := s.runs(engagement)
+	if err != nil {
+		return 0, err
+	}
+	if len(runs) == 0 {
+		return 0, errors.New("the store has no runs")
+	}
+	return runs[len(runs)-1].ID, nil
+}
+
+// setDecision records the review decision on a match of a run.
+func (s *store) setDecision(runID int64, match Match) error {
+	res, err := s.db.Exec(`UPDATE candidates SET decision = ? WHERE run_id = ? AND login = ? AND discarded = 0
+		AND employee_id = (SELECT id FROM employees WHERE run_id = ? AND key = ?)`,
+		match.Decision, runID, match.Login, runID, employeeKey(match.Employee))
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("run %d has no match of %s for %s", runID, match.Login, match.Employee.Name)
+	}
+	return nil
+}
+
+// errNoPreviousRun is returned by previousRun when the store has no run to
+// return, as opposed to failing to read the runs.
+var errNoPreviousRun = errors.New("the store has no earlier run")
+
+// previousRun returns the newest run of an engagement older than the run
+// with the given ID, or the latest run when before is 0.
+func (s *store) previousRun(engagement string, before int64) (int64, error) {
+	runs, err := s.runs(engagement)
+	if err != nil {
+		return 0, err
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if before == 0 || runs[i].ID < before {
+			return runs[i].ID, nil
+		}
+	}
+	if engagement == "" {
+		return 0, errNoPreviousRun
+	}
+	return 0, fmt.Errorf("%w of %s", errNoPreviousRun, engagement)
+}
+
+// filterResults keeps the matches scoring at least minScore whose decision is
+// one of decisions, when given, for exporting a subset of a run.
+func filterResults(results Results, minScore float64, decisions []string) Results {
+	wanted := make(map[string]bool)
+	for _, decision := range decisions {
+		wanted[decision] = true
+	}
+	var matches []Match
+	for _, match := range results.Matches {
+		if match.Score < minScore {
+			continue
+		}
+		if len(wanted) > 0 && !wanted[valueOr(match.Decision, "none")] {
+			continue
+		}
+		matches = append(matches, match)
+	}
+	results.Matches = matches
+	results.Discarded = nil
+	return results
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func runStore(args []string) error {
+	if len(args) == 0 || (args[0] != "import" && args[0] != "runs" && args[0] != "export") {
+		fmt.Fprint(os.Stderr, "Usage: mulef store import|runs|export [flags]\n")
+		return flag.ErrHelp
+	}
+
+	var fs *flag.FlagSet
+	switch args[0] {
+	case "import":
+		fs = newFlagSet("mulef store import", "Adds a results file to the store as a new run.")
+	case "runs":
+		fs = newFlagSet("mulef store runs", "Lists the runs in the store.")
+	case "export":
+		fs = newFlagSet("mulef store export", "Writes a run from the store, or a subset of its matches, to a results file for mulef report and mulef review.")
+	}
+	database := fs.String("db", "mulef.db", "path of the SQLite store")
+	engagement := fs.String("engagement", "", "engagement of the runs")
+	resultsFile := fs.String("results", "", "path of the results file")
+	runID := fs.Int64("run", 0, "ID of the run to export, defaults to the latest run of -engagement")
+	minScore := fs.Float64("min-score", 0, "export only matches scoring at least this much")
+	decisions := fs.String("decision", "", "export only matches with these review decisions, comma-separated (confirmed, rejected, unsure, none)")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if args[0] != "runs" && *resultsFile == "" {
+		return usageError(fs, "Results flag not specified")
+	}
+
+	db, err := openStore(*database)
+	if err != nil {
+		return err
+	}
+	defer db.close()
+
+	switch args[0] {
+	case "import":
+		results, err := loadResults(*resultsFile)
+		if err != nil {
+			return fmt.Errorf("can not read results: %v", err)
+		}
+		if *engagement != "" {
+			results.Engagement = *engagement
+		}
+		id, err := db.saveRun(results)
+		if err != nil {
+			return err
+		}
+		color.Green("[*] Imported %s as run %d", *resultsFile, id)
+
+	case "runs":
+		runs, err := db.runs(*engagement)
+		if err != nil {
+			return err
+		}
+		if len(runs) == 0 {
+			color.Yellow("[!] The store has no runs")
+			return nil
+		}
+		for _, run := range runs {
+			fmt.Printf("%4d  %s  %-20s %-9s %5d employees %5d matches\n", run.ID, run.StartedAt.Local().Format("2006-01-02 15:04"), valueOr(run.Engagement, "-"), run.Mode, run.Employees, run.Matches)
+		}
+
+	case "export":
+		id := *runID
+		if id == 0 {
+			if id, err = db.latestRun(*engagement); err != nil {
+				return err
+			}
+		}
+		results, err := db.loadRun(id)
+		if err != nil {
+			return err
+		}
+		results = filterResults(results, *minScore, splitList(*decisions))
+		if err := saveResults(*resultsFile, results); err != nil {
+			return err
+		}
+		color.Green("[*] Exported %d matches of run %d to %s", len(results.Matches), id, *resultsFile)
+	}
+	return nil
+}
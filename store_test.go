@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func schemaVersion(t *testing.T, s *store) int {
+	t.Helper()
+	var version int
+	if err := s.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func TestStoreMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mulef.db")
+
+	s, err := openStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := schemaVersion(t, s); got != len(storeMigrations) {
+		t.Errorf("new store has schema version %d, want %d", got, len(storeMigrations))
+	}
+	if _, err := s.saveRun(Results{Engagement: "acme", StartedAt: time.Now(), FinishedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	s.close()
+
+	// Reopening applies nothing twice.
+	if s, err = openStore(path); err != nil {
+		t.Fatalf("reopening the store: %v", err)
+	}
+	s.close()
+
+	// A new migration is applied on top of the existing data.
+	saved := storeMigrations
+	storeMigrations = append(append([]string(nil), saved...), `ALTER TABLE runs ADD COLUMN note TEXT NOT NULL DEFAULT 'migrated'`)
+	t.Cleanup(func() { storeMigrations = saved })
+	if s, err = openStore(path); err != nil {
+		t.Fatalf("migrating the store: %v", err)
+	}
+	var note string
+	if err := s.db.QueryRow(`SELECT note FROM runs WHERE engagement = 'acme'`).Scan(&note); err != nil || note != "migrated" {
+		t.Errorf("migrated run has note %q, %v", note, err)
+	}
+	if got := schemaVersion(t, s); got != len(storeMigrations) {
+		t.Errorf("migrated store has schema version %d, want %d", got, len(storeMigrations))
+	}
+	s.close()
+
+	// A failing migration leaves the version where it was.
+	storeMigrations = append(storeMigrations, `ALTER TABLE missing ADD COLUMN x TEXT`)
+	if _, err := openStore(path); err == nil {
+		t.Error("a failing migration was accepted")
+	}
+
+	// An older mulef refuses a store it does not understand.
+	storeMigrations = saved
+	if _, err := openStore(path); err == nil {
+		t.Error("a store with a newer schema was accepted")
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	s, err := openStore(filepath.Join(t.TempDir(), "mulef.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+
+	alice := Employee{URN: "urn:li:member:1", Name: "Alice", Location: "Berlin", Title: "Engineer", Email: "alice@acme.com", Status: employmentCurrent, ProfileURL: "https://www.linkedin.com/in/alice"}
+	bob := Employee{Name: "Bob", Location: "Paris"}
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	results := Results{
+		Engagement: "acme",
+		StartedAt:  started,
+		FinishedAt: started.Add(time.Minute),
+		Mode:       modeKeywords,
+		Keywords:   []string{"acme"},
+		Employees:  []Employee{alice, bob},
+		Weights:    map[string]float64{evidenceLocation: 1, evidenceOrgMember: 5},
+		Matches: []Match{{
+			Employee:   alice,
+			Login:      "alice",
+			ProfileURL: "https://github.com/alice",
+			Score:      6,
+			Ambiguous:  true,
+			Contenders: []string{"Bob"},
+			Decision:   decisionConfirmed,
+			Evidence: []Evidence{
+				{Kind: evidenceLocation, Detail: "Berlin"},
+				{Kind: evidenceOrgMember, Detail: "acme", URL: "https://github.com/acme"},
+			},
+		}},
+		Discarded: []Match{{Employee: bob, Login: "alice", Score: 1}},
+	}
+
+	runID, err := s.saveRun(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.loadRun(runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, results) {
+		t.Errorf("loadRun() = %+v\nwant %+v", got, results)
+	}
+
+	if _, err := s.saveRun(Results{Engagement: "other", StartedAt: started.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := s.runs("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []storedRun{{ID: runID, Engagement: "acme", StartedAt: started, Mode: modeKeywords, Employees: 2, Matches: 1}}
+	if !reflect.DeepEqual(runs, want) {
+		t.Errorf("runs() = %+v, want %+v", runs, want)
+	}
+	if all, err := s.runs(""); err != nil || len(all) != 2 {
+		t.Errorf("runs(\"\") = %d runs, %v", len(all), err)
+	}
+	if _, err := s.loadRun(runID + 100); err == nil {
+		t.Error("loadRun() of a missing run succeeded")
+	}
+}
+
+func TestFilterResults(t *testing.T) {
+	results := Results{
+		Matches: []Match{
+			{Login: "a", Score: 6, Decision: decisionConfirmed},
+			{Login: "b", Score: 2},
+			{Login: "c", Score: 4, Decision: decisionRejected},
+		},
+		Discarded: []Match{{Login: "d"}},
+	}
+	tests := []struct {
+		name      string
+		minScore  float64
+		decisions []string
+		want      []string
+	}{
+		{name: "everything", want: []string{"a", "b", "c"}},
+		{name: "min score", minScore: 3, want: []string{"a", "c"}},
+		{name: "undecided", decisions: []string{"none"}, want: []string{"b"}},
+		{name: "confirmed or rejected", decisions: []string{decisionConfirmed, decisionRejected}, minScore: 5, want: []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered := filterResults(results, tt.minScore, tt.decisions)
+			var got []string
+			for _, match := range filtered.Matches {
+				got = append(got, match.Login)
+			}
+			if !reflect.DeepEqual(got, tt.want) || filtered.Discarded != nil {
+				t.Errorf("filterResults() = %v, discarded %v, want %v", got, filtered.Discarded, tt.want)
+			}
+		})
+	}
+}
+
+func TestStorePreviousRun(t *testing.T) {
+	s, err := openStore(filepath.Join(t.TempDir(), "mulef.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var ids []int64
+	for i, engagement := range []string{"acme", "other", "acme"} {
+		id, err := s.saveRun(Results{Engagement: engagement, StartedAt: started.Add(time.Duration(i) * time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	tests := []struct {
+		name       string
+		engagement string
+		before     int64
+		want       int64
+		wantErr    bool
+	}{
+		{name: "latest", engagement: "acme", want: ids[2]},
+		{name: "before the latest", engagement: "acme", before: ids[2], want: ids[0]},
+		{name: "no earlier run", engagement: "acme", before: ids[0], wantErr: true},
+		{name: "other engagement", engagement: "other", before: ids[2], want: ids[1]},
+		{name: "any engagement", before: ids[2], want: ids[1]},
+		{name: "unknown engagement", engagement: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.previousRun(tt.engagement, tt.before)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("previousRun() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, errNoPreviousRun) {
+				t.Errorf("previousRun() error = %v, want errNoPreviousRun", err)
+			}
+			if got != tt.want {
+				t.Errorf("previousRun() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// A store that can not be read is an error, not a missing run.
+	s.close()
+	if _, err := s.previousRun("acme", 0); err == nil || errors.Is(err, errNoPreviousRun) {
+		t.Errorf("previousRun() on a closed store error = %v, want a read error", err)
+	}
+}
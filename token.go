@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v3"
+)
+
+// githubTokenEnvs are checked in order for a token, like the gh CLI does.
+var githubTokenEnvs = []string{"GITHUB_TOKEN", "GH_TOKEN"}
+
+// resolveGitHubToken finds the GitHub token to use and describes where it
+// came from. The -token flag wins, then -token-file, the environment, the gh
+// CLI's hosts.yml and finally mulef's own keyring file.
+func resolveGitHubToken(token string, tokenFile string) (string, string, error) {
+	if token != "" {
+		return token, "-token", nil
+	}
+	if tokenFile != "" {
+		token, err := readTokenFile(tokenFile)
+		if err != nil {
+			return "", "", err
+		}
+		return token, tokenFile, nil
+	}
+	for _, name := range githubTokenEnvs {
+		if token := strings.TrimSpace(os.Getenv(name)); token != "" {
+			return token, name, nil
+		}
+	}
+	if path := ghHostsFile(); path != "" {
+		if token, err := readGhHostsToken(path); err == nil && token != "" {
+			return token, path, nil
+		}
+	}
+	if path, err := keyringFile(); err == nil {
+		if token, err := readTokenFile(path); err == nil {
+			return token, path, nil
+		}
+	}
+	return "", "", errors.New("no GitHub token found, set GITHUB_TOKEN, pass -token-file, log in with gh auth login or save one with mulef token save")
+}
+
+func readTokenFile(filename string) (string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(filename); err == nil && runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		color.Yellow("[!] %s is readable by other users, restrict it with chmod 600", filename)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("%s is empty", filename)
+	}
+	return token, nil
+}
+
+// ghHostsFile is where the gh CLI keeps its logins when it has no system
+// keyring to store them in.
+func ghHostsFile() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "hosts.yml")
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh", "hosts.yml")
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("AppData"); dir != "" {
+			return filepath.Join(dir, "GitHub CLI", "hosts.yml")
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gh", "hosts.yml")
+}
+
+func readGhHostsToken(filename string) (string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	var hosts map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	}
+	if err := yaml.Unmarshal(data, &hosts); err != nil {
+		return "", err
+	}
+	return hosts["github.com"].OAuthToken, nil
+}
+
+// keyringFile is the file mulef token save stores a token in. It fails when
+// the user has no config directory, rather than falling back to the current
+// one.
+func keyringFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("no config directory to keep the token in: %v", err)
+	}
+	return filepath.Join(dir, "mulef", "github-token"), nil
+}
+
+// writeTokenFile writes token to path readable only by the user, even when
+// path already exists with wider permissions: the token goes to a new file
+// that then replaces it.
+func writeTokenFile(path string, token string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), ".github-token-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.WriteString(token + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+type rateLimit struct {
+	Limit     int   `json:"limit"`
+	Remaining int   `json:"remaining"`
+	Reset     int64 `json:"reset"`
+}
+
+type githubTokenStatus struct {
+	Scopes     []string
+	Core       rateLimit
+	Search     rateLimit
+	CodeSearch rateLimit
+	GraphQL    rateLimit
+}
+
+// checkGitHubToken asks /rate_limit, which costs nothing, whether the token
+// is valid and how much of its budget is left.
+func checkGitHubToken(token string) (githubTokenStatus, error) {
+	req, err := http.NewRequest("GET", "https://api.github.com/rate_limit", nil)
+	if err != nil {
+		return githubTokenStatus{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return githubTokenStatus{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return githubTokenStatus{}, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return githubTokenStatus{}, errors.New("GitHub rejected the token (HTTP 401), it is invalid, expired or revoked")
+	case resp.StatusCode != http.StatusOK:
+		return githubTokenStatus{}, fmt.Errorf("GitHub answered /rate_limit with HTTP %d: %s", resp.StatusCode, snippet(body))
+	}
+
+	var limits struct {
+		Resources struct {
+			Core       rateLimit `json:"core"`
+			Search     rateLimit `json:"search"`
+			CodeSearch rateLimit `json:"code_search"`
+			GraphQL    rateLimit `json:"graphql"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(body, &limits); err != nil {
+		return githubTokenStatus{}, fmt.Errorf("can not read /rate_limit: %v", err)
+	}
+
+	status := githubTokenStatus{
+		Core:       limits.Resources.Core,
+		Search:     limits.Resources.Search,
+		CodeSearch: limits.Resources.CodeSearch,
+		GraphQL:    limits.Resources.GraphQL,
+	}
+	for _, scope := range strings.Split(resp.Header.Get("X-OAuth-Scopes"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			status.Scopes = append(status.Scopes, scope)
+		}
+	}
+	return status, nil
+}
+
+// printGitHubTokenStatus reports the token check and warns when a budget the
+// run depends on is used up.
+func printGitHubTokenStatus(source string, status githubTokenStatus) {
+	scopes := "none listed, fine-grained or app token"
+	if len(status.Scopes) > 0 {
+		scopes = strings.Join(status.Scopes, ", ")
+	}
+	color.Cyan("[+] GitHub token from %s, scopes: %s", source, scopes)
+	color.Cyan("[+] Rate limit: core %d/%d, search %d/%d, code search %d/%d, graphql %d/%d",
+		status.Core.Remaining, status.Core.Limit,
+		status.Search.Remaining, status.Search.Limit,
+		status.CodeSearch.Remaining, status.CodeSearch.Limit,
+		status.GraphQL.Remaining, status.GraphQL.Limit)
+
+	budgets := []struct {
+		name  string
+		limit rateLimit
+	}{{"core", status.Core}, {"search", status.Search}, {"code search", status.CodeSearch}, {"graphql", status.GraphQL}}
+	for _, budget := range budgets {
+		if budget.limit.Limit > 0 && budget.limit.Remaining == 0 {
+			color.Yellow("[!] The %s budget is used up until %s", budget.name, time.Unix(budget.limit.Reset, 0).Format("15:04:05"))
+		}
+	}
+}
+
+func runToken(args []string) error {
+	if len(args) == 0 || (args[0] != "check" && args[0] != "save") {
+		fmt.Fprint(os.Stderr, "Usage: mulef token check|save [flags]\n")
+		return flag.ErrHelp
+	}
+
+	if args[0] == "save" {
+		fs := newFlagSet("mulef token save", "Reads a GitHub token from standard input and stores it in mulef's config directory, readable only by you.")
+		if err := parseFlags(fs, args[1:]); err != nil {
+			return err
+		}
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("can not read token: %v", err)
+		}
+		token := strings.TrimSpace(line)
+		if token == "" {
+			return errors.New("no token given on standard input")
+		}
+		path, err := keyringFile()
+		if err != nil {
+			return err
+		}
+		if err := writeTokenFile(path, token); err != nil {
+			return err
+		}
+		color.Green("[*] Saved the token to %s", path)
+		return nil
+	}
+
+	fs := newFlagSet("mulef token check", "Checks the GitHub token mulef would use and prints its scopes and rate limit.")
+	var network networkOptions
+	network.register(fs)
+	token := fs.String("token", "", "github token, prefer GITHUB_TOKEN or -token-file")
+	tokenFile := fs.String("token-file", "", "path of a file holding the github token")
+	if err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if err := network.apply(); err != nil {
+		return err
+	}
+
+	resolved, source, err := resolveGitHubToken(*token, *tokenFile)
+	if err != nil {
+		return err
+	}
+	status, err := checkGitHubToken(resolved)
+	if err != nil {
+		return err
+	}
+	printGitHubTokenStatus(source, status)
+	return nil
+}
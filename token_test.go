@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolveGitHubToken(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte(" from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ghDir := filepath.Join(dir, "gh")
+	if err := os.MkdirAll(ghDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ghDir, "hosts.yml"), []byte("github.com:\n    oauth_token: from-gh\n    user: alice\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		flag       string
+		file       string
+		env        map[string]string
+		want       string
+		wantSource string
+		wantErr    bool
+	}{
+		{name: "flag", flag: "from-flag", file: tokenFile, env: map[string]string{"GITHUB_TOKEN": "from-env"}, want: "from-flag", wantSource: "-token"},
+		{name: "file", file: tokenFile, env: map[string]string{"GITHUB_TOKEN": "from-env"}, want: "from-file", wantSource: tokenFile},
+		{name: "missing file", file: filepath.Join(dir, "missing"), wantErr: true},
+		{name: "GITHUB_TOKEN", env: map[string]string{"GITHUB_TOKEN": "from-env", "GH_TOKEN": "from-gh-env"}, want: "from-env", wantSource: "GITHUB_TOKEN"},
+		{name: "GH_TOKEN", env: map[string]string{"GH_TOKEN": "from-gh-env"}, want: "from-gh-env", wantSource: "GH_TOKEN"},
+		{name: "gh hosts", env: map[string]string{"GH_CONFIG_DIR": ghDir}, want: "from-gh", wantSource: filepath.Join(ghDir, "hosts.yml")},
+		{name: "nothing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_TOKEN", tt.env["GITHUB_TOKEN"])
+			t.Setenv("GH_TOKEN", tt.env["GH_TOKEN"])
+			ghConfig := tt.env["GH_CONFIG_DIR"]
+			if ghConfig == "" {
+				ghConfig = filepath.Join(dir, "no-gh")
+			}
+			t.Setenv("GH_CONFIG_DIR", ghConfig)
+			t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "no-config"))
+			t.Setenv("HOME", filepath.Join(dir, "no-home"))
+
+			got, source, err := resolveGitHubToken(tt.flag, tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveGitHubToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want || source != tt.wantSource {
+				t.Errorf("resolveGitHubToken() = %q from %q, want %q from %q", got, source, tt.want, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestCheckGitHubToken(t *testing.T) {
+	withHTTPClient(t, roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("Authorization") != "Bearer good" {
+			return stubResponse(http.StatusUnauthorized, `{"message":"Bad credentials"}`), nil
+		}
+		resp := stubResponse(http.StatusOK, `{"resources":{"core":{"limit":5000,"remaining":4999,"reset":1},"search":{"limit":30,"remaining":0,"reset":2},"code_search":{"limit":10,"remaining":10,"reset":3}}}`)
+		resp.Header.Set("X-OAuth-Scopes", "repo, read:org")
+		return resp, nil
+	}))
+
+	status, err := checkGitHubToken("good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := githubTokenStatus{
+		Scopes:     []string{"repo", "read:org"},
+		Core:       rateLimit{Limit: 5000, Remaining: 4999, Reset: 1},
+		Search:     rateLimit{Limit: 30, Remaining: 0, Reset: 2},
+		CodeSearch: rateLimit{Limit: 10, Remaining: 10, Reset: 3},
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("checkGitHubToken() = %+v, want %+v", status, want)
+	}
+	if _, err := checkGitHubToken("bad"); err == nil {
+		t.Error("checkGitHubToken() accepted a rejected token")
+	}
+}
+
+func TestTokenSave(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing bool
+		noConfig bool
+		wantErr  bool
+	}{
+		{name: "new file"},
+		{name: "existing file readable by others", existing: true},
+		{name: "no config directory", noConfig: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "mulef", "github-token")
+			t.Setenv("XDG_CONFIG_HOME", dir)
+			if tt.noConfig {
+				t.Setenv("XDG_CONFIG_HOME", "")
+				t.Setenv("HOME", "")
+			}
+			if tt.existing {
+				if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte("old\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			withStdin(t, "new-token\n")
+
+			err := runToken([]string{"save"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm != 0o600 {
+				t.Errorf("token file mode = %o, want 600", perm)
+			}
+			if token, err := readTokenFile(path); err != nil || token != "new-token" {
+				t.Errorf("saved token = %q, %v, want new-token", token, err)
+			}
+			entries, err := os.ReadDir(filepath.Dir(path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("%d files left in the config directory, want only the token", len(entries))
+			}
+		})
+	}
+}
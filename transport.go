@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpClient is shared by every LinkedIn and GitHub request so the proxy and
+// TLS settings apply to both. main replaces it once the flags are parsed.
+var httpClient = &http.Client{
+	Transport: newTransport(http.ProxyFromEnvironment, false),
+	Timeout:   60 * time.Second,
+}
+
+func newTransport(proxy func(*http.Request) (*url.URL, error), insecure bool) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	if insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return transport
+}
+
+// newHTTPClient returns a client that sends its requests through
+// proxyAddress, which may be an http, https or socks5 URL. Without a proxy
+// address the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are
+// honoured. insecure disables TLS certificate verification, which is only
+// needed behind intercepting proxies such as Burp.
+func newHTTPClient(proxyAddress string, insecure bool) (*http.Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if proxyAddress != "" {
+		if !strings.Contains(proxyAddress, "://") {
+			proxyAddress = "http://" + proxyAddress
+		}
+		proxyURL, err := url.Parse(proxyAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %v", proxyAddress, err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q, use http, https or socks5", proxyURL.Scheme)
+		}
+		if proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q: missing host", proxyAddress)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Client{
+		Transport: newTransport(proxy, insecure),
+		Timeout:   60 * time.Second,
+	}, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNewHTTPClientProxy(t *testing.T) {
+	tests := []struct {
+		proxy   string
+		want    string
+		wantErr bool
+	}{
+		{proxy: "127.0.0.1:8080", want: "http://127.0.0.1:8080"},
+		{proxy: "socks5://localhost:1080", want: "socks5://localhost:1080"},
+		{proxy: "ftp://localhost:21", wantErr: true},
+		{proxy: "http://", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.proxy, func(t *testing.T) {
+			client, err := newHTTPClient(tt.proxy, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newHTTPClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			req, _ := http.NewRequest(http.MethodGet, "https://www.linkedin.com/", nil)
+			proxyURL, err := client.Transport.(*http.Transport).Proxy(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if proxyURL.String() != tt.want {
+				t.Errorf("proxy = %s, want %s", proxyURL, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientInsecure(t *testing.T) {
+	client, err := newHTTPClient("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := client.Transport.(*http.Transport).TLSClientConfig
+	if config == nil || !config.InsecureSkipVerify {
+		t.Error("insecure client verifies certificates")
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// watchCacheTTL is the default -cache-ttl of watch. A rescan has to see the
+// accounts created since the previous run, so every cached response is
+// revalidated, and an unchanged one costs no rate limit.
+const watchCacheTTL = 0
+
+func runWatch(name string, args []string) error {
+	fs := newFlagSet(name, "Reruns a target on a schedule, stores every run and sends what changed since the previous run to the sinks.")
+	var linkedIn linkedInOptions
+	var github githubOptions
+	var network networkOptions
+	var config configOptions
+	var sinks sinkOptions
+	linkedIn.register(fs)
+	github.register(fs)
+	network.register(fs)
+	network.setDefaultCacheTTL(fs, watchCacheTTL)
+	config.register(fs)
+	sinks.register(fs)
+	employeesFile := fs.String("employees", "", "path of a CSV or JSON employee list to use instead of LinkedIn")
+	scheduleFlag := fs.String("schedule", "@daily", "when to run: five cron fields such as \"0 3 * * 1\", @hourly, @daily, @weekly or @every <duration>")
+	now := fs.Bool("now", false, "run once right away, then follow the schedule")
+	metricsAddress := fs.String("metrics", "", "serve Prometheus metrics on this address, e.g. 127.0.0.1:9090")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := config.apply(fs, "employees"); err != nil {
+		return err
+	}
+	github.profile = config.profile
+	if *employeesFile == "" {
+		if err := linkedIn.validate(fs); err != nil {
+			return err
+		}
+	}
+	if err := github.validate(fs); err != nil {
+		return err
+	}
+	if github.database == "" {
+		return usageError(fs, "Db flag not specified, watch keeps every run in the store")
+	}
+	if github.engagement == "" {
+		return usageError(fs, "Engagement flag not specified, runs are compared within an engagement")
+	}
+	schedule, err := parseSchedule(*scheduleFlag)
+	if err != nil {
+		return usageError(fs, err.Error())
+	}
+	// Matches are not sent one by one, only what changed between runs.
+	sinkList, err := sinks.sinks()
+	if err != nil {
+		return usageError(fs, err.Error())
+	}
+	if err := network.apply(); err != nil {
+		return err
+	}
+	enableProgress()
+	if *metricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", serveMetrics)
+		listener, err := net.Listen("tcp", *metricsAddress)
+		if err != nil {
+			return err
+		}
+		defer listener.Close()
+		go http.Serve(listener, mux)
+		color.Cyan("[+] Serving metrics on http://%s/metrics", listener.Addr())
+	}
+	if len(sinkList) == 0 {
+		color.Yellow("[!] No -sink given, changes are only printed")
+	}
+
+	runOnce := func() error {
+		var employees []Employee
+		var err error
+		if *employeesFile != "" {
+			if employees, err = loadEmployees(*employeesFile); err != nil {
+				return fmt.Errorf("can not read employees: %v", err)
+			}
+		} else if employees, err = linkedIn.fetch(); err != nil {
+			return err
+		}
+
+		run, err := github.match(employees)
+		if err != nil {
+			return err
+		}
+		diff := run.Diff
+		if diff == nil {
+			color.Cyan("[+] First run of %s, nothing to compare with yet", github.engagement)
+			return nil
+		}
+		if diff.empty() {
+			return nil
+		}
+		event := Event{Type: eventDelta, Time: time.Now(), Engagement: github.engagement, Diff: diff}
+		for _, sink := range sinkList {
+			if err := sink.Send(github.ctx, event); err != nil {
+				color.Red("[-] Can not send the changes to %s: %v", sink, err)
+				continue
+			}
+			color.Green("[*] Sent the changes to %s", sink)
+		}
+		return nil
+	}
+
+	// A signal also cancels the run going on, including its waits for GitHub
+	// rate limits to reset.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	linkedIn.ctx, github.ctx = ctx, ctx
+
+	due := *now
+	for {
+		if !due {
+			next := schedule.next(time.Now())
+			if next.IsZero() {
+				return errors.New("the schedule never matches")
+			}
+			color.Cyan("[+] Next run of %s at %s", github.engagement, next.Format("2006-01-02 15:04"))
+			select {
+			case <-time.After(time.Until(next)):
+			case <-ctx.Done():
+				color.Cyan("[+] Stopped watching")
+				return nil
+			}
+		}
+		due = false
+
+		// A failed run, an expired LinkedIn session for instance, should not
+		// end the watch, the next one may succeed.
+		if err := runOnce(); err != nil {
+			if ctx.Err() != nil {
+				color.Cyan("[+] Stopped watching, the run going on was not saved")
+				return nil
+			}
+			color.Red("[-] Run failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchCacheTTL(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want time.Duration
+	}{
+		{name: "default", want: watchCacheTTL},
+		{name: "given", args: []string{"-cache-ttl", "1h"}, want: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+			var network networkOptions
+			network.register(fs)
+			network.setDefaultCacheTTL(fs, watchCacheTTL)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if network.cacheTTL != tt.want {
+				t.Errorf("cacheTTL = %v, want %v", network.cacheTTL, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatchSeesNewAccounts(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		want []string
+	}{
+		{name: "watch revalidates", ttl: watchCacheTTL, want: []string{"alice", "alice bob"}},
+		{name: "a day of cache hides the new account", ttl: defaultCacheTTL, want: []string{"alice", "alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts := []string{"alice"}
+			withHTTPClient(t, &cachingTransport{
+				dir: t.TempDir(),
+				ttl: tt.ttl,
+				next: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+					etag := `"` + strings.Join(accounts, "-") + `"`
+					if req.Header.Get("If-None-Match") == etag {
+						return stubResponse(http.StatusNotModified, ""), nil
+					}
+					var items []string
+					for _, login := range accounts {
+						items = append(items, `{"login":"`+login+`"}`)
+					}
+					resp := stubResponse(http.StatusOK, `{"items":[`+strings.Join(items, ",")+`]}`)
+					resp.Header.Set("ETag", etag)
+					return resp, nil
+				}),
+			})
+
+			for run, want := range tt.want {
+				if run > 0 {
+					accounts = append(accounts, "bob")
+				}
+				found, err := searchUsers(context.Background(), "t", "Alice")
+				if err != nil {
+					t.Fatal(err)
+				}
+				var logins []string
+				for _, user := range found.Items {
+					logins = append(logins, user.Login)
+				}
+				if got := strings.Join(logins, " "); got != want {
+					t.Errorf("run %d found %q, want %q", run+1, got, want)
+				}
+			}
+		})
+	}
+}